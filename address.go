@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// unlock script prefix of a P2PKH input, followed by the 33 bytes compressed public key
+const p2pkhUnlockPrefix = "00"
+
+// base58Encode encodes bytes with the bitcoin alphabet used by Alephium addresses
+func base58Encode(input []byte) string {
+	x := new(big.Int).SetBytes(input)
+	base := big.NewInt(58)
+	mod := new(big.Int)
+
+	var encoded []byte
+	for x.Sign() > 0 {
+		x.DivMod(x, base, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+
+	// leading zeros are encoded as the first character
+	for _, b := range input {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+
+	return string(encoded)
+}
+
+// base58Decode decodes a string encoded with base58Encode
+func base58Decode(input string) ([]byte, error) {
+	x := new(big.Int)
+	base := big.NewInt(58)
+	for _, c := range input {
+		index := strings.IndexRune(base58Alphabet, c)
+		if index < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		x.Mul(x, base).Add(x, big.NewInt(int64(index)))
+	}
+
+	var zeros []byte
+	for _, c := range input {
+		if c != rune(base58Alphabet[0]) {
+			break
+		}
+		zeros = append(zeros, 0)
+	}
+
+	return append(zeros, x.Bytes()...), nil
+}
+
+// isValidAddress checks the encoding of an address: a type byte, P2PKH, P2MPKH, P2SH or P2C, followed by at
+// least a 32 bytes hash
+func isValidAddress(address string) bool {
+	decoded, err := base58Decode(address)
+	if err != nil || len(decoded) < 33 {
+		return false
+	}
+
+	return decoded[0] <= 3
+}
+
+// addressFromUnlockScript derives the address spending an input, only P2PKH inputs are supported
+func addressFromUnlockScript(unlockScript string) (string, error) {
+	scriptBytes, err := hex.DecodeString(unlockScript)
+	if err != nil {
+		return "", fmt.Errorf("cannot decode unlock script: %w", err)
+	}
+
+	if len(scriptBytes) != 34 || unlockScript[0:2] != p2pkhUnlockPrefix {
+		return "", fmt.Errorf("unlock script is not P2PKH")
+	}
+
+	pubKeyHash := blake2b.Sum256(scriptBytes[1:])
+	lockupScript := append([]byte{0x00}, pubKeyHash[:]...)
+
+	return base58Encode(lockupScript), nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// bitcoin base58 vectors, Alephium uses the same alphabet
+var base58Vectors = []struct {
+	hex     string
+	encoded string
+}{
+	{"", ""},
+	{"61", "2g"},
+	{"626262", "a3gV"},
+	{"636363", "aPEr"},
+	{"73696d706c792061206c6f6e6720737472696e67", "2cFupjhnEsSn59qHXstmK2ffpLv2"},
+	{"00eb15231dfceb60925886b67d065299925915aeb172c06647", "1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L"},
+	{"516b6fcd0f", "ABnLTmg"},
+	{"bf4f89001e670274dd", "3SEo3LWLoPntC"},
+	{"572e4794", "3EFU7m"},
+	{"ecac89cad93923c02321", "EJDM8drfXA6uyA"},
+	{"10c8511e", "Rt5zm"},
+	{"00000000000000000000", "1111111111"},
+}
+
+func TestBase58Encode(t *testing.T) {
+	for _, vector := range base58Vectors {
+		input, _ := hex.DecodeString(vector.hex)
+		if got := base58Encode(input); got != vector.encoded {
+			t.Errorf("base58Encode(%s) = %s, want %s", vector.hex, got, vector.encoded)
+		}
+	}
+}
+
+func TestBase58Decode(t *testing.T) {
+	for _, vector := range base58Vectors {
+		want, _ := hex.DecodeString(vector.hex)
+		got, err := base58Decode(vector.encoded)
+		if err != nil {
+			t.Errorf("base58Decode(%s) error: %s", vector.encoded, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("base58Decode(%s) = %x, want %s", vector.encoded, got, vector.hex)
+		}
+	}
+
+	if _, err := base58Decode("0OIl"); err == nil {
+		t.Error("base58Decode accepted characters outside the alphabet")
+	}
+}
+
+func TestIsValidAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		valid   bool
+	}{
+		{"12CDM92h4vYHCoWD72w9SCPghFjTVy1ekvyJdp3XtFc8z", true},
+		{"vSxdLL2kE3o6vJBdKkdgmS3W39G3ZEmFHpo2y1jnbzBq", true},
+		// 32 bytes, the hash is too short
+		{"1AujpupFP4KWeZvqA7itsHY9cLJmx4qTzojVZrg8W9y", false},
+		{"../../infos/node", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := isValidAddress(test.address); got != test.valid {
+			t.Errorf("isValidAddress(%s) = %t, want %t", test.address, got, test.valid)
+		}
+	}
+}
+
+func TestAddressFromUnlockScript(t *testing.T) {
+	tests := []struct {
+		unlockScript string
+		address      string
+		fails        bool
+	}{
+		{unlockScript: "00d1b70d2226308b46da297486adb6b4f1a8c1842cb159ac5ec04f384fe2d6f5da28", address: "12CDM92h4vYHCoWD72w9SCPghFjTVy1ekvyJdp3XtFc8z"},
+		// P2MPKH
+		{unlockScript: "01d1b70d2226308b46da297486adb6b4f1a8c1842cb159ac5ec04f384fe2d6f5da28", fails: true},
+		{unlockScript: "00d1b70d", fails: true},
+		{unlockScript: "not hex", fails: true},
+	}
+
+	for _, test := range tests {
+		address, err := addressFromUnlockScript(test.unlockScript)
+		if test.fails {
+			if err == nil {
+				t.Errorf("addressFromUnlockScript(%s) = %s, want an error", test.unlockScript, address)
+			}
+			continue
+		}
+
+		if err != nil || address != test.address {
+			t.Errorf("addressFromUnlockScript(%s) = %s, %v, want %s", test.unlockScript, address, err, test.address)
+		}
+	}
+}
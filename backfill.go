@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// max time range queried at once on the fullnode blocks endpoint
+const backfillTimeChunk = 10 * time.Minute
+
+type BackfillOptions struct {
+	fromHeight int
+	toHeight   int
+	fromTime   time.Time
+	toTime     time.Time
+	notify     bool
+	report     string
+	workers    int
+}
+
+// runBackfill walks past blocks of every chain and runs them through the same pipeline as live blocks.
+// Usage: backfill [-from-height N -to-height M | -from 2024-01-01 -to 2024-01-08] [-notify] [-report alerts.csv]
+func runBackfill(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	fromHeight := fs.Int("from-height", -1, "first height to check on every chain")
+	toHeight := fs.Int("to-height", -1, "last height to check on every chain, default to the current height")
+	fromTime := fs.String("from", "", "start of the time range, RFC3339 or YYYY-MM-DD")
+	toTime := fs.String("to", "", "end of the time range, RFC3339 or YYYY-MM-DD, default to now")
+	notify := fs.Bool("notify", false, "send alerts to telegram/twitter")
+	report := fs.String("report", "", "write alerts to this csv file")
+	workers := fs.Int("workers", 10, "number of workers checking transactions")
+	fs.Parse(args)
+
+	opts := BackfillOptions{fromHeight: *fromHeight, toHeight: *toHeight, notify: *notify, report: *report, workers: *workers}
+
+	if *fromTime != "" {
+		t, err := parseBackfillTime(*fromTime)
+		if err != nil {
+			log.Fatalf("cannot parse from time, err: %s\n", err)
+		}
+		opts.fromTime = t
+
+		opts.toTime = time.Now()
+		if *toTime != "" {
+			opts.toTime, err = parseBackfillTime(*toTime)
+			if err != nil {
+				log.Fatalf("cannot parse to time, err: %s\n", err)
+			}
+		}
+	} else if opts.fromHeight < 0 {
+		log.Fatalf("backfill needs -from-height or -from\n")
+	}
+
+	if !opts.notify && opts.report == "" {
+		log.Fatalf("backfill needs -notify and/or -report\n")
+	}
+
+	updateTokens()
+	updateKnownWallet()
+	updatePrice()
+
+	if opts.notify {
+		notifiers = NewNotifierRegistry(parameters.Notifiers)
+	}
+
+	backfill(opts)
+}
+
+func parseBackfillTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, value)
+}
+
+func backfill(opts BackfillOptions) {
+	chTxs := make(chan Tx, 300)
+	chMessages := make(chan Message, 300)
+
+	var wgTxs sync.WaitGroup
+	for w := 1; w <= opts.workers; w++ {
+		wgTxs.Add(1)
+		go func(wId int) {
+			defer wgTxs.Done()
+			for tx := range chTxs {
+				getTxData(tx, chMessages, wId)
+				txQueueMetrics.Dec()
+			}
+		}(w)
+	}
+
+	var wgMessages sync.WaitGroup
+	wgMessages.Add(1)
+	go func() {
+		defer wgMessages.Done()
+		consumeBackfillMessages(chMessages, opts)
+	}()
+
+	var nbBlocks int
+	if !opts.fromTime.IsZero() {
+		nbBlocks = backfillByTime(opts.fromTime, opts.toTime, chTxs)
+	} else {
+		nbBlocks = backfillByHeight(opts.fromHeight, opts.toHeight, chTxs)
+	}
+
+	close(chTxs)
+	wgTxs.Wait()
+	close(chMessages)
+	wgMessages.Wait()
+
+	log.Printf("Backfill done, %d blocks checked\n", nbBlocks)
+}
+
+func backfillByHeight(fromHeight int, toHeight int, chTxs chan Tx) int {
+	nbBlocks := 0
+	for groupFrom := 0; groupFrom < groupNum; groupFrom++ {
+		for groupTo := 0; groupTo < groupNum; groupTo++ {
+			lastHeight := toHeight
+			if lastHeight < 0 {
+				currentHeight, err := getChainHeight(groupFrom, groupTo)
+				if err != nil {
+					log.Printf("Cannot get height of chain %d -> %d, err: %s\n", groupFrom, groupTo, err)
+					continue
+				}
+				lastHeight = currentHeight
+			}
+
+			log.Printf("Backfill chain %d -> %d from height %d to %d\n", groupFrom, groupTo, fromHeight, lastHeight)
+			for height := fromHeight; height <= lastHeight; height++ {
+				blocks, err := getBlocksAtHeight(groupFrom, groupTo, height)
+				if err != nil {
+					log.Printf("Cannot get blocks at height %d for chain %d -> %d, err: %s\n", height, groupFrom, groupTo, err)
+					continue
+				}
+
+				for _, block := range blocks {
+					if backfillBlock(block, chTxs) {
+						nbBlocks++
+					}
+				}
+			}
+		}
+	}
+
+	return nbBlocks
+}
+
+func backfillByTime(fromTime time.Time, toTime time.Time, chTxs chan Tx) int {
+	nbBlocks := 0
+	for start := fromTime; start.Before(toTime); start = start.Add(backfillTimeChunk) {
+		end := start.Add(backfillTimeChunk)
+		if end.After(toTime) {
+			end = toTime
+		}
+
+		log.Printf("Backfill blocks from %s to %s\n", start.Format(time.RFC3339), end.Format(time.RFC3339))
+		// the fullnode range is inclusive, avoid checking the blocks at the chunk boundary twice
+		blocks, err := getBlocksByTime(start.UnixMilli(), end.UnixMilli()-1)
+		if err != nil {
+			log.Printf("Cannot get blocks from %s to %s, err: %s\n", start, end, err)
+			continue
+		}
+
+		for _, block := range blocks {
+			if backfillBlock(block, chTxs) {
+				nbBlocks++
+			}
+		}
+	}
+
+	return nbBlocks
+}
+
+// backfillBlock checks the transactions of a past block, uncles are skipped
+func backfillBlock(block *Ws, chTxs chan Tx) bool {
+	isGhost, err := isGhostUncle(block.Params.Hash)
+	if err != nil {
+		log.Printf("Error checking if block is ghost uncle: %v", err)
+	}
+
+	if isGhost {
+		return false
+	}
+
+	dispatchBlockTxs(block, chTxs, false)
+	return true
+}
+
+func consumeBackfillMessages(chMessages chan Message, opts BackfillOptions) {
+	var reportWriter *csv.Writer
+	if opts.report != "" {
+		f, err := os.Create(opts.report)
+		if err != nil {
+			log.Fatalf("cannot create report file, err: %s\n", err)
+		}
+		defer f.Close()
+
+		reportWriter = csv.NewWriter(f)
+		defer reportWriter.Flush()
+		reportWriter.Write([]string{"txId", "groupFrom", "groupTo", "from", "fromName", "to", "toName", "amount", "symbol"})
+	}
+
+	nbAlerts := 0
+	for msg := range chMessages {
+		notificationQueueMetric.Dec()
+		nbAlerts++
+
+		if opts.notify {
+			notifiers.publish(newNotification(msg))
+		}
+
+		if reportWriter != nil {
+			amount, symbol := msg.humanAmount()
+			reportWriter.Write([]string{
+				msg.txId,
+				strconv.Itoa(msg.groupFrom),
+				strconv.Itoa(msg.groupTo),
+				msg.from,
+				getAddressName(&msg.from).Name,
+				msg.to,
+				getAddressName(&msg.to).Name,
+				strconv.FormatFloat(amount, 'f', -1, 64),
+				symbol,
+			})
+		}
+	}
+
+	if opts.notify {
+		// wait for the sinks to send every alert
+		notifiers.close()
+	}
+
+	log.Printf("Backfill found %d alerts\n", nbAlerts)
+}
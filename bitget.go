@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"strconv"
@@ -24,14 +23,16 @@ type BitgetAggTrades struct {
 func getTradesBitget(from int64, to int64, chMessagesCex chan MessageCex) {
 
 	var trades BitgetAggTrades
-	dataBytes, _, err := getHttp(fmt.Sprintf("https://api.bitget.com/api/v2/spot/market/fills-history?symbol=ALPHUSDT&limit=1000&startTime=%d&endTime=%d", from, to))
+	url := fmt.Sprintf("https://api.bitget.com/api/v2/spot/market/fills-history?symbol=ALPHUSDT&limit=1000&startTime=%d&endTime=%d", from, to)
+	dataBytes, _, err := getHttp(url)
 	if err != nil {
-		log.Printf("Error getting price\n%s\n", err)
+		log.Printf("Error getting bitget trades, err: %s\n", err)
 		return
 	}
 
-	if len(dataBytes) > 0 {
-		json.Unmarshal(dataBytes, &trades)
+	if err := decodeJson(url, dataBytes, &trades); err != nil {
+		log.Printf("Error parsing bitget trades, err: %s\n", err)
+		return
 	}
 
 	for _, v := range trades.Data {
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	blueskyDefaultPds = "https://bsky.social"
+	// 1666 records created per hour for an account
+	blueskyRateLimit = 1666.0 / 3600
+)
+
+var urlRegexp = regexp.MustCompile(`https?://[^\s]+`)
+
+type BlueskySession struct {
+	AccessJwt string `json:"accessJwt"`
+	Did       string `json:"did"`
+}
+
+type BlueskyFacetIndex struct {
+	ByteStart int `json:"byteStart"`
+	ByteEnd   int `json:"byteEnd"`
+}
+
+type BlueskyFacetFeature struct {
+	Type string `json:"$type"`
+	Uri  string `json:"uri"`
+}
+
+// BlueskyFacet marks a link in the text, clients do not detect links themselves
+type BlueskyFacet struct {
+	Index    BlueskyFacetIndex     `json:"index"`
+	Features []BlueskyFacetFeature `json:"features"`
+}
+
+type BlueskyPost struct {
+	Type      string         `json:"$type"`
+	Text      string         `json:"text"`
+	CreatedAt string         `json:"createdAt"`
+	Facets    []BlueskyFacet `json:"facets,omitempty"`
+}
+
+type BlueskyCreateRecord struct {
+	Repo       string      `json:"repo"`
+	Collection string      `json:"collection"`
+	Record     BlueskyPost `json:"record"`
+}
+
+type BlueskyNotifier struct {
+	pds        string
+	identifier string
+	password   string
+	client     *retryablehttp.Client
+
+	sessionMu sync.Mutex
+	session   *BlueskySession
+}
+
+func init() {
+	registerNotifier("bluesky", newBlueskyNotifier)
+}
+
+func newBlueskyNotifier() (Notifier, error) {
+	identifier := os.Getenv("BLUESKY_IDENTIFIER")
+	password := os.Getenv("BLUESKY_APP_PASSWORD")
+	if identifier == "" || password == "" {
+		return nil, fmt.Errorf("BLUESKY_IDENTIFIER and BLUESKY_APP_PASSWORD are not set")
+	}
+
+	pds := strings.TrimSuffix(os.Getenv("BLUESKY_PDS"), "/")
+	if pds == "" {
+		pds = blueskyDefaultPds
+	}
+
+	return &BlueskyNotifier{pds: pds, identifier: identifier, password: password, client: newHttpClient()}, nil
+}
+
+func (b *BlueskyNotifier) Name() string {
+	return "bluesky"
+}
+
+func (b *BlueskyNotifier) Format() Format {
+	return formatText
+}
+
+// MaxLength is 300 graphemes, counted in bytes to stay below it
+func (b *BlueskyNotifier) MaxLength() int {
+	return 300
+}
+
+func (b *BlueskyNotifier) RateLimit() float64 {
+	return blueskyRateLimit
+}
+
+// Send creates a post with a facet for each link, the session is created again once when it expired
+func (b *BlueskyNotifier) Send(ctx context.Context, text string) error {
+	err := b.createPost(ctx, text)
+
+	var httpErr *HttpError
+	if errors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusUnauthorized || strings.Contains(httpErr.Error(), "ExpiredToken")) {
+		b.sessionMu.Lock()
+		b.session = nil
+		b.sessionMu.Unlock()
+
+		return b.createPost(ctx, text)
+	}
+
+	return err
+}
+
+func (b *BlueskyNotifier) createPost(ctx context.Context, text string) error {
+	session, err := b.getSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	record := BlueskyCreateRecord{
+		Repo:       session.Did,
+		Collection: "app.bsky.feed.post",
+		Record: BlueskyPost{
+			Type:      "app.bsky.feed.post",
+			Text:      text,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+			Facets:    blueskyLinkFacets(text),
+		},
+	}
+
+	_, err = b.post(ctx, "com.atproto.repo.createRecord", session.AccessJwt, record)
+	return err
+}
+
+func (b *BlueskyNotifier) getSession(ctx context.Context) (*BlueskySession, error) {
+	b.sessionMu.Lock()
+	defer b.sessionMu.Unlock()
+
+	if b.session != nil {
+		return b.session, nil
+	}
+
+	body, err := b.post(ctx, "com.atproto.server.createSession", "", map[string]string{"identifier": b.identifier, "password": b.password})
+	if err != nil {
+		return nil, fmt.Errorf("cannot create bluesky session: %w", err)
+	}
+
+	var session BlueskySession
+	if err := json.Unmarshal(body, &session); err != nil {
+		return nil, fmt.Errorf("cannot parse bluesky session: %w", err)
+	}
+	b.session = &session
+
+	return b.session, nil
+}
+
+func (b *BlueskyNotifier) post(ctx context.Context, method string, accessJwt string, value any) ([]byte, error) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal %s: %w", method, err)
+	}
+
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPost, b.pds+"/xrpc/"+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create bluesky request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if accessJwt != "" {
+		req.Header.Set("Authorization", "Bearer "+accessJwt)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, newHttpError(b.pds, 0, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newHttpError(b.pds, resp.StatusCode, err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, newHttpError(b.pds, resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, respBody))
+	}
+
+	return respBody, nil
+}
+
+// blueskyLinkFacets returns a link facet for each url, indexed in UTF-8 bytes
+func blueskyLinkFacets(text string) []BlueskyFacet {
+	var facets []BlueskyFacet
+	for _, match := range urlRegexp.FindAllStringIndex(text, -1) {
+		facets = append(facets, BlueskyFacet{
+			Index:    BlueskyFacetIndex{ByteStart: match[0], ByteEnd: match[1]},
+			Features: []BlueskyFacetFeature{{Type: "app.bsky.richtext.facet#link", Uri: text[match[0]:match[1]]}},
+		})
+	}
+
+	return facets
+}
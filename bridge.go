@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+)
+
+// symbols valued 1 USD
+var stableSymbols = map[string]bool{"USDT": true, "USDC": true, "DAI": true}
+
+// BridgeData is a transfer into or out of a bridge contract, amounts are in human units
+type BridgeData struct {
+	contract string
+	chain    string
+	tokenId  string
+	outgoing bool
+	user     string
+	symbol   string
+	amount   float64
+	usd      float64
+	// false when no price of the token is known, usd is then 0
+	priced bool
+}
+
+// token prices in ALPH from the last DEX swaps, to value tokens without external price
+type TokenPrices struct {
+	mu     sync.Mutex
+	prices map[string]float64
+}
+
+var tokenPrices = &TokenPrices{prices: make(map[string]float64)}
+
+func (t *TokenPrices) set(tokenId string, priceAlph float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prices[tokenId] = priceAlph
+}
+
+func (t *TokenPrices) get(tokenId string) (float64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	price, ok := t.prices[tokenId]
+	return price, ok
+}
+
+// tokenUsdPrice returns the USD price of ALPH or a token, false if unknown
+func tokenUsdPrice(tokenId string, symbol string) (float64, bool) {
+	if tokenId == alphFlowId {
+		return coinGeckoPrice, coinGeckoPrice > 0
+	}
+
+	if stableSymbols[strings.ToUpper(symbol)] {
+		return 1, true
+	}
+
+	if priceAlph, ok := tokenPrices.get(tokenId); ok && coinGeckoPrice > 0 {
+		return priceAlph * coinGeckoPrice, true
+	}
+
+	return 0, false
+}
+
+// wormhole ids of the chains the bridge connects to
+var wormholeChains = map[int]string{1: "Solana", 2: "Ethereum", 4: "BSC", 5: "Polygon", 6: "Avalanche", 23: "Arbitrum", 24: "Optimism", 30: "Base"}
+
+// loadBridgeContracts parses "address;Ethereum,address;BSC", contracts of the bridge for each target chain. The
+// chain is the fallback when the target chain cannot be decoded from the message of the transfer
+func loadBridgeContracts() map[string]string {
+	contracts := make(map[string]string)
+
+	value := os.Getenv("BRIDGE_CONTRACTS")
+	if value == "" {
+		return contracts
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ";")
+		if len(parts) != 2 {
+			log.Printf("cannot parse bridge contract %s\n", item)
+			continue
+		}
+		contracts[parts[0]] = parts[1]
+	}
+
+	return contracts
+}
+
+// wormholeTargetChain decodes the target chain of a token transfer payload, with (id 3) or without (id 1) a
+// payload for the recipient: id, amount, token address, token chain, recipient then the target chain
+func wormholeTargetChain(payloadHex string) (int, bool) {
+	payload, err := hex.DecodeString(payloadHex)
+	if err != nil || len(payload) < 101 || (payload[0] != 1 && payload[0] != 3) {
+		return 0, false
+	}
+
+	return int(binary.BigEndian.Uint16(payload[99:101])), true
+}
+
+// bridgeTargetChain returns the target chain of an outgoing transfer from the message the bridge published through
+// the core contract, false if the transaction has no such message
+func bridgeTargetChain(txId string) (string, bool) {
+	if parameters.BridgeCoreContract == "" {
+		return "", false
+	}
+
+	events, err := fullnodeClient.txEvents(context.Background(), txId)
+	if err != nil {
+		log.Printf("Cannot get events of bridge tx %s, err: %s\n", txId, err)
+		return "", false
+	}
+
+	for _, event := range events.Events {
+		if event.ContractAddress != parameters.BridgeCoreContract {
+			continue
+		}
+
+		for _, field := range event.Fields {
+			if field.ValByteVec == nil {
+				continue
+			}
+
+			if chainId, ok := wormholeTargetChain(field.ValByteVec.Value); ok {
+				if name, known := wormholeChains[chainId]; known {
+					return name, true
+				}
+				return fmt.Sprintf("chain %d", chainId), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// decodeBridgeTransfers returns the assets moved into (outgoing) or out of (incoming) the bridge contracts
+func decodeBridgeTransfers(flows []AssetFlow) []BridgeData {
+	var transfers []BridgeData
+	for _, flow := range flows {
+		for _, recipient := range flow.recipients {
+			if chain, ok := parameters.BridgeContracts[recipient.address]; ok && len(flow.senders) > 0 {
+				transfers = append(transfers, newBridgeData(flow, recipient, chain, true, flow.senders[0].address))
+			}
+		}
+
+		for _, sender := range flow.senders {
+			if chain, ok := parameters.BridgeContracts[sender.address]; ok && len(flow.recipients) > 0 {
+				transfers = append(transfers, newBridgeData(flow, sender, chain, false, flow.recipients[0].address))
+			}
+		}
+	}
+
+	return transfers
+}
+
+func newBridgeData(flow AssetFlow, contract AddressAmount, chain string, outgoing bool, user string) BridgeData {
+	amountFloat, _ := new(big.Float).SetInt(contract.amount).Float64()
+
+	bridgeData := BridgeData{contract: contract.address, chain: chain, tokenId: flow.tokenId, outgoing: outgoing, user: user}
+	if flow.tokenId == alphFlowId {
+		bridgeData.symbol = "ALPH"
+		bridgeData.amount = amountFloat / baseAlph
+	} else {
+		tokenData := searchTokenData(flow.tokenId)
+		bridgeData.symbol = tokenData.Symbol
+		bridgeData.amount = amountFloat / math.Pow(10.0, float64(tokenData.Decimals))
+	}
+
+	if price, ok := tokenUsdPrice(flow.tokenId, bridgeData.symbol); ok {
+		bridgeData.usd = bridgeData.amount * price
+		bridgeData.priced = true
+	}
+
+	return bridgeData
+}
+
+// checkBridge sends an alert for each bridge transfer above the USD trigger, returns true if the transaction
+// goes through the bridge
+func checkBridge(flows []AssetFlow, txId Tx, chMessages chan Message) bool {
+	transfers := decodeBridgeTransfers(flows)
+
+	// the target chain of outgoing transfers is in the message of the bridge, mempool txs have no events yet
+	outgoing := false
+	for _, transfer := range transfers {
+		outgoing = outgoing || transfer.outgoing
+	}
+	if outgoing && txId.status != statusPending {
+		if targetChain, ok := bridgeTargetChain(txId.id); ok {
+			for i := range transfers {
+				if transfers[i].outgoing {
+					transfers[i].chain = targetChain
+				}
+			}
+		}
+	}
+
+	for _, transfer := range transfers {
+		if !transfer.priced {
+			// no DEX price seen yet, e.g. after a restart, the transfer trigger of the token applies
+			minAmount, ok := bridgeMinAmount(transfer.tokenId)
+			if !ok || transfer.amount < minAmount {
+				bridgeUnpricedMetric.WithLabelValues("skipped").Inc()
+				log.Printf("Skipping unpriced bridge transfer of %f %s in tx %s\n", transfer.amount, transfer.symbol, txId.id)
+				continue
+			}
+			bridgeUnpricedMetric.WithLabelValues("alerted").Inc()
+		} else if transfer.usd < parameters.MinAmountBridgeTriggerUsd {
+			// small transfers, like the ALPH deposit of the bridge contracts, are ignored
+			continue
+		}
+
+		bridgeCopy := transfer
+		bridgeTransfersMetric.Inc()
+		emitMessage(Message{
+			from:      transfer.user,
+			to:        transfer.contract,
+			txId:      txId.id,
+			groupFrom: txId.groupFrom,
+			groupTo:   txId.groupTo,
+			blockHash: txId.blockHash,
+			height:    txId.height,
+			status:    txId.status,
+			bridge:    &bridgeCopy,
+		}, chMessages)
+	}
+
+	return len(transfers) > 0
+}
+
+// bridgeMinAmount returns the transfer trigger of ALPH or a tracked token, false for other tokens
+func bridgeMinAmount(tokenId string) (float64, bool) {
+	if tokenId == alphFlowId {
+		return parameters.MinAmountTrigger, true
+	}
+
+	minAmount, ok := trackTokens[tokenId]
+	return minAmount, ok
+}
+
+// bridgeFormat formats a bridge alert
+func bridgeFormat(msg Message, isTelegram bool) string {
+	bridge := msg.bridge
+
+	userWallet := getAddressName(&bridge.user)
+	user, _ := formatAddress(&userWallet, bridge.user, bridge.amount, false)
+
+	direction := fmt.Sprintf("Alephium -> %s", bridge.chain)
+	if !bridge.outgoing {
+		direction = fmt.Sprintf("%s -> Alephium", bridge.chain)
+	}
+
+	amount := Amount{Value: bridge.amount, Symbol: "$" + bridge.symbol}.formatHuman()
+	amountFiat := "unknown price"
+	if bridge.priced {
+		amountFiat = Amount{Value: bridge.usd, Symbol: "USDT"}.formatHuman()
+	}
+
+	status := statusTag(msg.status)
+
+	var text string
+	if isTelegram {
+		text = fmt.Sprintf("%s🌉 Bridge %s\n%s bridged %s (%s)\n\n<a href='%s/#/transactions/%s'>TX link</a>\n", status, direction, user, amount, amountFiat, parameters.FrontendExplorerUrl, msg.txId)
+	} else {
+		text = fmt.Sprintf("%s🌉 Bridge %s\n%s bridged %s (%s)\n\n%s/#/transactions/%s\n", status, direction, user, amount, amountFiat, parameters.FrontendExplorerUrl, msg.txId)
+	}
+
+	return text
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWormholeTargetChain(t *testing.T) {
+	// id, amount, token address, token chain, recipient, target chain, fee
+	transfer := func(id string, targetChain string) string {
+		return id + strings.Repeat("00", 32) + strings.Repeat("11", 32) + "00ff" + strings.Repeat("22", 32) + targetChain + strings.Repeat("00", 32)
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		chain   int
+		ok      bool
+	}{
+		{"transfer to Ethereum", transfer("01", "0002"), 2, true},
+		{"transfer with payload to BSC", transfer("03", "0004"), 4, true},
+		{"attestation", transfer("02", "0002"), 0, false},
+		{"too short", "01" + strings.Repeat("00", 98), 0, false},
+		{"not hex", "zz", 0, false},
+	}
+
+	for _, test := range tests {
+		chain, ok := wormholeTargetChain(test.payload)
+		if chain != test.chain || ok != test.ok {
+			t.Errorf("%s: wormholeTargetChain() = %d, %t, want %d, %t", test.name, chain, ok, test.chain, test.ok)
+		}
+	}
+}
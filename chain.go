@@ -1,53 +1,63 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"math"
+	"math/big"
+	"math/rand"
 	"net/url"
-	"os"
-	"os/signal"
-	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+type OutputRef struct {
+	Hint int    `json:"hint"`
+	Key  string `json:"key"`
+}
+
+type TokenAmount struct {
+	ID     string `json:"id"`
+	Amount string `json:"amount"`
+}
+
+type TxInput struct {
+	OutputRef      OutputRef     `json:"outputRef"`
+	UnlockScript   string        `json:"unlockScript"`
+	TxHashRef      string        `json:"txHashRef"`
+	Address        string        `json:"address"`
+	AttoAlphAmount string        `json:"attoAlphAmount"`
+	Tokens         []TokenAmount `json:"tokens,omitempty"`
+}
+
+type TxOutput struct {
+	Type           string        `json:"type"`
+	Hint           int           `json:"hint"`
+	Key            string        `json:"key"`
+	AttoAlphAmount string        `json:"attoAlphAmount"`
+	Address        string        `json:"address"`
+	Tokens         []TokenAmount `json:"tokens,omitempty"`
+	Message        string        `json:"message"`
+	Spent          string        `json:"spent"`
+}
+
 type Transaction struct {
-	Type      string `json:"type"`
-	Hash      string `json:"hash"`
-	BlockHash string `json:"blockHash"`
-	Timestamp int64  `json:"timestamp"`
-	Inputs    []struct {
-		OutputRef struct {
-			Hint int    `json:"hint"`
-			Key  string `json:"key"`
-		} `json:"outputRef"`
-		UnlockScript   string `json:"unlockScript"`
-		TxHashRef      string `json:"txHashRef"`
-		Address        string `json:"address"`
-		AttoAlphAmount string `json:"attoAlphAmount"`
-	} `json:"inputs"`
-	Outputs []struct {
-		Type           string `json:"type"`
-		Hint           int    `json:"hint"`
-		Key            string `json:"key"`
-		AttoAlphAmount string `json:"attoAlphAmount"`
-		Address        string `json:"address"`
-		Tokens         []struct {
-			ID     string `json:"id"`
-			Amount string `json:"amount"`
-		} `json:"tokens,omitempty"`
-		Message string `json:"message"`
-		Spent   string `json:"spent"`
-	} `json:"outputs"`
-	GasAmount         int    `json:"gasAmount"`
-	GasPrice          string `json:"gasPrice"`
-	ScriptExecutionOk bool   `json:"scriptExecutionOk"`
-	Coinbase          bool   `json:"coinbase"`
+	Type              string     `json:"type"`
+	Hash              string     `json:"hash"`
+	BlockHash         string     `json:"blockHash"`
+	Timestamp         int64      `json:"timestamp"`
+	Inputs            []TxInput  `json:"inputs"`
+	Outputs           []TxOutput `json:"outputs"`
+	GasAmount         int        `json:"gasAmount"`
+	GasPrice          string     `json:"gasPrice"`
+	ScriptExecutionOk bool       `json:"scriptExecutionOk"`
+	Coinbase          bool       `json:"coinbase"`
 }
 
 type Method string
@@ -70,10 +80,6 @@ type TokenList struct {
 	Tokens    []Token `json:"tokens"`
 }
 
-type HeightResponse struct {
-	CurrentHeight int `json:"currentHeight"`
-}
-
 type Ws struct {
 	Method Method `json:"method"`
 	Params struct {
@@ -85,27 +91,39 @@ type Ws struct {
 		Deps         []string `json:"deps"`
 		Transactions []struct {
 			Unsigned struct {
-				TxID         string `json:"txId"`
-				Version      int    `json:"version"`
-				NetworkID    int    `json:"networkId"`
-				GasAmount    int    `json:"gasAmount"`
-				GasPrice     string `json:"gasPrice"`
-				Inputs       []any  `json:"inputs"`
+				TxID      string `json:"txId"`
+				Version   int    `json:"version"`
+				NetworkID int    `json:"networkId"`
+				GasAmount int    `json:"gasAmount"`
+				GasPrice  string `json:"gasPrice"`
+				Inputs    []struct {
+					OutputRef    OutputRef `json:"outputRef"`
+					UnlockScript string    `json:"unlockScript"`
+				} `json:"inputs"`
 				FixedOutputs []struct {
-					Hint           int    `json:"hint"`
-					Key            string `json:"key"`
-					AttoAlphAmount string `json:"attoAlphAmount"`
-					Address        string `json:"address"`
-					Tokens         []any  `json:"tokens"`
-					LockTime       int64  `json:"lockTime"`
-					Message        string `json:"message"`
+					Hint           int           `json:"hint"`
+					Key            string        `json:"key"`
+					AttoAlphAmount string        `json:"attoAlphAmount"`
+					Address        string        `json:"address"`
+					Tokens         []TokenAmount `json:"tokens"`
+					LockTime       int64         `json:"lockTime"`
+					Message        string        `json:"message"`
 				} `json:"fixedOutputs"`
 			} `json:"unsigned"`
-			ScriptExecutionOk bool  `json:"scriptExecutionOk"`
-			ContractInputs    []any `json:"contractInputs"`
-			GeneratedOutputs  []any `json:"generatedOutputs"`
-			InputSignatures   []any `json:"inputSignatures"`
-			ScriptSignatures  []any `json:"scriptSignatures"`
+			ScriptExecutionOk bool        `json:"scriptExecutionOk"`
+			ContractInputs    []OutputRef `json:"contractInputs"`
+			GeneratedOutputs  []struct {
+				Type           string        `json:"type"`
+				Hint           int           `json:"hint"`
+				Key            string        `json:"key"`
+				AttoAlphAmount string        `json:"attoAlphAmount"`
+				Address        string        `json:"address"`
+				Tokens         []TokenAmount `json:"tokens"`
+				LockTime       int64         `json:"lockTime"`
+				Message        string        `json:"message"`
+			} `json:"generatedOutputs"`
+			InputSignatures  []any `json:"inputSignatures"`
+			ScriptSignatures []any `json:"scriptSignatures"`
 		} `json:"transactions"`
 		Nonce        string `json:"nonce"`
 		Version      int    `json:"version"`
@@ -123,33 +141,48 @@ const (
 	maxWorkers  = 50
 	queueSize   = 500
 	taskTimeout = 30 * time.Minute
+	// how long a producer waits for room in the queue before the block is spilled
+	queueBlockTimeout = 5 * time.Second
+	// max number of spilled blocks, past it producers wait for room in the queue
+	maxSpilled      = 10000
+	refillInterval  = 5 * time.Second
+	monitorInterval = 30 * time.Second
 )
 
 type Task struct {
-	data    *Ws
-	ch      chan Tx
-	retries int
+	data *Ws
+	ch   chan Tx
+}
+
+// spilledBlock is a block which did not fit in the queue, only its hash is kept to fetch it again later
+type spilledBlock struct {
+	hash string
+	ch   chan Tx
 }
 
 type QueueMetrics struct {
 	dropped   atomic.Int64
+	spilled   atomic.Int64
 	queued    atomic.Int64
 	processed atomic.Int64
 }
 
 type TaskQueue struct {
-	tasks    chan Task
-	workers  chan struct{}
-	shutdown chan struct{}
-	metrics  *QueueMetrics
+	tasks   chan Task
+	workers chan struct{}
+	stage   *Stage
+	metrics *QueueMetrics
+
+	spilledMu sync.Mutex
+	spilled   []spilledBlock
 }
 
 func NewTaskQueue() *TaskQueue {
 	tq := &TaskQueue{
-		tasks:    make(chan Task, queueSize),
-		workers:  make(chan struct{}, maxWorkers),
-		shutdown: make(chan struct{}),
-		metrics:  &QueueMetrics{},
+		tasks:   make(chan Task, queueSize),
+		workers: make(chan struct{}, maxWorkers),
+		stage:   NewStage(),
+		metrics: &QueueMetrics{},
 	}
 
 	// Start monitoring
@@ -157,6 +190,7 @@ func NewTaskQueue() *TaskQueue {
 
 	// Start workers
 	for i := 0; i < maxWorkers; i++ {
+		tq.stage.wg.Add(1)
 		go tq.worker()
 	}
 	workersMetrics.Set(float64(maxWorkers))
@@ -166,15 +200,18 @@ func NewTaskQueue() *TaskQueue {
 }
 
 func (tq *TaskQueue) monitorQueue() {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(monitorInterval)
 	defer ticker.Stop()
+	refillTicker := time.NewTicker(refillInterval)
+	defer refillTicker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			log.Printf("Queue stats - Queued: %d, Processed: %d, Dropped: %d, Queue Size: %d/%d, Workers: %d/%d",
+			log.Printf("Queue stats - Queued: %d, Processed: %d, Spilled: %d, Dropped: %d, Queue Size: %d/%d, Workers: %d/%d",
 				tq.metrics.queued.Load(),
 				tq.metrics.processed.Load(),
+				tq.metrics.spilled.Load(),
 				tq.metrics.dropped.Load(),
 				len(tq.tasks),
 				cap(tq.tasks),
@@ -182,43 +219,119 @@ func (tq *TaskQueue) monitorQueue() {
 				cap(tq.workers))
 			queuedMetrics.Set(float64(tq.metrics.processed.Load()))
 			inqueueMetrics.Set(float64(len(tq.tasks)))
-		case <-tq.shutdown:
+		case <-refillTicker.C:
+			tq.refill()
+		case <-tq.stage.ctx.Done():
 			return
 		}
 	}
 }
 
+// worker processes the tasks, once the queue is stopped it leaves when the queue is empty
 func (tq *TaskQueue) worker() {
-	for {
+	defer tq.stage.wg.Done()
+
+	consume(tq.stage, tq.tasks, func(task Task) {
+		tq.workers <- struct{}{}
+		getTxIdWs(task.data, task.ch)
+		<-tq.workers
+		tq.metrics.processed.Add(1)
+		processedMetrics.Inc()
+	})
+}
+
+// push queues the task, waiting up to queueBlockTimeout for room so producers slow down with the workers.
+// When the queue stays full the block is spilled, once the spill is full too the producer waits for room as the
+// block is pending in the cursor until it is processed
+func (tq *TaskQueue) push(task Task) {
+	select {
+	case tq.tasks <- task:
+		tq.metrics.queued.Add(1)
+		queuedMetrics.Inc()
+		return
+	case <-time.After(queueBlockTimeout):
+		if tq.spill(task) {
+			return
+		}
+	case <-tq.stage.ctx.Done():
+		// the block is not processed, it is replayed from the cursor at the next start
+		return
+	}
+
+	if task.data.Method != block_notify {
+		tq.metrics.dropped.Add(1)
+		droppedTasksMetric.Inc()
+		log.Printf("Queue full, dropping task %s\n", task.data.Params.Hash)
+		return
+	}
+
+	log.Printf("Queue and spill full, waiting for room to queue block %s\n", task.data.Params.Hash)
+	select {
+	case tq.tasks <- task:
+		tq.metrics.queued.Add(1)
+		queuedMetrics.Inc()
+	case <-tq.stage.ctx.Done():
+	}
+}
+
+// spill keeps only the hash of the block to fetch it again once the queue has room, returns false past maxSpilled
+func (tq *TaskQueue) spill(task Task) bool {
+	tq.spilledMu.Lock()
+	defer tq.spilledMu.Unlock()
+
+	if task.data.Method != block_notify || len(tq.spilled) >= maxSpilled {
+		return false
+	}
+
+	tq.spilled = append(tq.spilled, spilledBlock{hash: task.data.Params.Hash, ch: task.ch})
+	tq.metrics.spilled.Add(1)
+	spilledTasksMetric.Set(float64(len(tq.spilled)))
+
+	return true
+}
+
+// refill fetches the spilled blocks again while the queue is less than half full
+func (tq *TaskQueue) refill() {
+	for len(tq.tasks) < cap(tq.tasks)/2 {
+		tq.spilledMu.Lock()
+		if len(tq.spilled) == 0 {
+			tq.spilledMu.Unlock()
+			return
+		}
+		spilled := tq.spilled[0]
+		tq.spilledMu.Unlock()
+
+		block, err := getBlockFullnode(spilled.hash)
+		if err != nil {
+			log.Printf("Cannot fetch spilled block %s, err: %s\n", spilled.hash, err)
+			return
+		}
+
 		select {
-		case <-tq.shutdown:
+		case tq.tasks <- Task{data: block, ch: spilled.ch}:
+			retriedTasksMetrics.Inc()
+		default:
 			return
-		case task := <-tq.tasks:
-			select {
-			case tq.workers <- struct{}{}:
-				getTxIdWs(task.data, task.ch)
-				<-tq.workers // Release worker
-				tq.metrics.processed.Add(1)
-				processedMetrics.Inc()
-			default:
-				// Worker pool full - retry task
-				time.Sleep(time.Duration(task.retries*100) * time.Millisecond)
-				task.retries++
-				retriedTasksMetrics.Inc()
-				// Put task back in queue
-				go func() {
-					tq.tasks <- task
-				}()
-				log.Printf("Retrying task, attempt %d", task.retries)
-			}
 		}
+
+		tq.spilledMu.Lock()
+		tq.spilled = tq.spilled[1:]
+		spilledTasksMetric.Set(float64(len(tq.spilled)))
+		tq.spilledMu.Unlock()
 	}
 }
 
 var taskQueue = NewTaskQueue()
 
-var done chan interface{}
-var interrupt chan os.Signal
+const (
+	wsMinBackoff  = 1 * time.Second
+	wsMaxBackoff  = 2 * time.Minute
+	wsPingTimeout = 10 * time.Second
+
+	// a height of a gap is fetched again before the replay stops until the next session
+	gapFetchAttempts   = 4
+	gapFetchRetryDelay = 1 * time.Second
+)
 
 var ignoredAddressPairs = map[string]string{
 	"18KQPq3dJ9W4kXLWmtfMsRsptMRpkXe4HQCbRwXpw93jk": "12T7yHLpB1kaMBdHSApYM7H8aGXAET55axMiijJZYtK5G",
@@ -229,44 +342,94 @@ var ignoredAddressPairs = map[string]string{
 	"141Sf75o3SxyskgdHCsBmiW2AXqk5r2v3oqCC9bhbMdBd": "1DEmoThKNJ8KTwsBU8snPTjF7e9AG7fUbh9uaNemGwREp",
 }
 
-// find transactions in each blocks
-func getBlocksFullnode(ch chan Tx) {
+// find transactions in each blocks, until the context is done
+func getBlocksFullnode(ctx context.Context, ch chan Tx) {
+	backoff := wsMinBackoff
+	for {
+		connected, stop := runWsSession(ctx, ch)
+		if stop {
+			return
+		}
 
-	//interrupt := make(chan os.Signal, 1)
-	//signal.Notify(interrupt, os.Interrupt)
+		// connection was up, start again with the shortest delay
+		if connected {
+			backoff = wsMinBackoff
+		}
 
-	u := url.URL{Scheme: "wss", Host: parameters.WsFullnode, Path: "/events"}
-	done = make(chan interface{})    // Channel to indicate that the receiverHandler is done
-	interrupt = make(chan os.Signal) // Channel to listen for interrupt signal to terminate gracefully
+		// add up to 50% of jitter to not reconnect at the same time as everyone else
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)/2+1))
+		log.Printf("Websocket disconnected, reconnecting in %s\n", wait)
 
-	signal.Notify(interrupt, os.Interrupt) // Notify the interrupt channel for SIGINT
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			log.Println("Shutdown while reconnecting. Exiting....")
+			return
+		}
+
+		backoff *= 2
+		if backoff > wsMaxBackoff {
+			backoff = wsMaxBackoff
+		}
+		wsReconnectsMetric.Inc()
+	}
+
+}
+
+// runWsSession connects to the fullnode websocket and process blocks until the connection is lost.
+// It returns if the connection was established and if the watcher has to stop
+func runWsSession(ctx context.Context, ch chan Tx) (bool, bool) {
+	wsHost := fullnodePool.wsHost()
+	u := url.URL{Scheme: "wss", Host: wsHost, Path: "/events"}
+	done := make(chan interface{}) // Channel to indicate that the receiverHandler is done
+
+	// heights to resume from, before live blocks move the cursor
+	resumeFrom := chainCursor.startResume()
 
 	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
 	if err != nil {
-		log.Fatal("Error connecting to Websocket Server:", err)
+		log.Println("Error connecting to Websocket Server:", err)
+		return false, false
 	}
 	defer conn.Close()
-	go receiveHandler(conn, ch)
+	wsConnectedMetric.Set(1)
+	defer wsConnectedMetric.Set(0)
+
+	go receiveHandler(conn, ch, done)
+
+	// fetch blocks we could have missed while disconnected or down, the next session takes over the replay
+	sessionCtx, cancelSession := context.WithCancel(ctx)
+	defer cancelSession()
+	go resumeChains(sessionCtx, ch, resumeFrom)
 
 	for {
 		select {
-		case <-time.After(time.Duration(10) * time.Millisecond * 1000):
+		case <-time.After(wsPingTimeout):
+			// switch to a healthier fullnode, missed blocks are fetched when reconnecting
+			if !fullnodePool.isBestWs(wsHost) {
+				log.Printf("Fullnode %s is not the healthiest anymore, switching\n", wsHost)
+				return true, false
+			}
+
 			// Send an echo packet every 10 second
 			err := conn.WriteMessage(websocket.TextMessage, []byte("ping"))
 			if err != nil {
 				log.Println("Error during writing to websocket:", err)
-				return
+				return true, false
 			}
 
-		case <-interrupt:
-			// We received a SIGINT (Ctrl + C). Terminate gracefully...
-			log.Println("Received SIGINT interrupt signal. Closing all pending connections")
+		case <-done:
+			return true, false
+
+		case <-ctx.Done():
+			// We received a SIGINT or SIGTERM. Terminate gracefully...
+			log.Println("Shutdown. Closing all pending connections")
 
 			// Close our websocket connection
 			err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 			if err != nil {
 				log.Println("Error during closing websocket:", err)
-				return
+				return true, true
 			}
 
 			select {
@@ -275,13 +438,12 @@ func getBlocksFullnode(ch chan Tx) {
 			case <-time.After(time.Duration(1) * time.Second):
 				log.Println("Timeout in closing receiving channel. Exiting....")
 			}
-			return
+			return true, true
 		}
 	}
-
 }
 
-func receiveHandler(connection *websocket.Conn, ch chan Tx) {
+func receiveHandler(connection *websocket.Conn, ch chan Tx, done chan interface{}) {
 	defer close(done)
 	for {
 		_, msg, err := connection.ReadMessage()
@@ -296,150 +458,164 @@ func receiveHandler(connection *websocket.Conn, ch chan Tx) {
 			continue
 		}
 
-		task := Task{
-			data:    &data,
-			ch:      ch,
-			retries: 0,
+		if data.Method == block_notify && !chainCursor.markSeen(data.Params.Hash, data.Params.ChainFrom, data.Params.ChainTo, data.Params.Height) {
+			continue
 		}
 
-		// Keep trying to queue task with exponential backoff
-		go func(t Task) {
-			backoff := time.Millisecond * 100
-			for {
-				select {
-				case taskQueue.tasks <- t:
-					taskQueue.metrics.queued.Add(1)
-					queuedMetrics.Inc()
-					return
-				default:
-					time.Sleep(backoff)
-					backoff *= 2
-					if backoff > time.Second*10 {
-						backoff = time.Second * 10
-					}
-				}
-			}
-		}(task)
+		queueBlock(&data, ch)
 	}
 }
 
-func getTxIdWs(block *Ws, chTxs chan Tx) {
-	if block.Method == block_notify {
-
-		for {
-			cntRetry := 0
-			if getHeightFullnodeState(block.Params.ChainFrom, block.Params.ChainTo, block.Params.Height) {
-				isGhost, err := isGhostUncle(block.Params.Hash)
-				//log.Printf("Block %s is ghost uncle: %v", block.Params.Hash, isGhost)
-				if err != nil {
-					log.Printf("Error checking if block is ghost uncle: %v", err)
-				}
+// resumeChains fetches from the fullnode every block between the processed height and the current height of each
+// chain. A chain whose blocks cannot be fetched keeps its gap for the next session
+func resumeChains(ctx context.Context, ch chan Tx, fromHeights map[ChainIndex]int) {
+	for groupFrom := 0; groupFrom < groupNum; groupFrom++ {
+		for groupTo := 0; groupTo < groupNum; groupTo++ {
+			if ctx.Err() != nil {
+				return
+			}
 
-				if isGhost {
-					log.Printf("Block %s is a ghost uncle.", block.Params.Hash)
-					return
-				}
+			chain := ChainIndex{groupFrom, groupTo}
+			lastHeight, ok := fromHeights[chain]
+			if !ok {
+				// nothing processed yet on this chain, nothing to resume
+				continue
+			}
 
-				if cntRetry >= maxRetry {
-					return
-				}
+			currentHeight, err := getChainHeight(groupFrom, groupTo)
+			if err != nil {
+				log.Printf("Cannot resume chain %d -> %d, err: %s\n", groupFrom, groupTo, err)
+				continue
+			}
 
-				cntRetry++
+			filled, err := fillChainGap(ctx, chain, lastHeight+1, currentHeight, ch)
+			gapFillSizeMetrics.Observe(float64(filled))
+			if err != nil {
+				log.Printf("Stopped resuming chain %d -> %d, resumed by the next session, err: %s\n", groupFrom, groupTo, err)
+				continue
+			}
 
-				break
+			chainCursor.closeGap(chain)
+			if filled > 0 {
+				log.Printf("Resumed chain %d -> %d, %d blocks from height %d to %d\n", groupFrom, groupTo, filled, lastHeight+1, currentHeight)
 			}
-			time.Sleep(10 * time.Second)
 		}
+	}
+}
 
-		for _, tx := range block.Params.Transactions {
-
-			// no input mean coinbase tx
-			if len(tx.Unsigned.Inputs) > 0 {
-				txId := Tx{id: tx.Unsigned.TxID, groupFrom: block.Params.ChainFrom, groupTo: block.Params.ChainTo, height: block.Params.Height}
+// fillChainGap queues every block between two heights (included) and returns the number of blocks queued. It
+// stops at the first height which cannot be fetched, the gap of the cursor then starts at this height
+func fillChainGap(ctx context.Context, chain ChainIndex, fromHeight int, toHeight int, ch chan Tx) (int, error) {
+	filled := 0
+	for height := fromHeight; height <= toHeight; height++ {
+		blocks, err := getBlocksAtHeightRetry(ctx, chain, height)
+		if err != nil {
+			return filled, err
+		}
 
-				txQueueMetrics.Inc()
-				chTxs <- txId
+		for _, block := range blocks {
+			if !chainCursor.markSeen(block.Params.Hash, block.Params.ChainFrom, block.Params.ChainTo, block.Params.Height) {
+				continue
 			}
-		}
 
+			queueBlock(block, ch)
+			filled++
+			gapFillBlocksMetrics.Inc()
+		}
+		chainCursor.advanceGap(chain, height+1)
 	}
+
+	return filled, nil
 }
 
-func isGhostUncle(blockHash string) (bool, error) {
-	url := fmt.Sprintf("https://%s/blockflow/is-block-in-main-chain?blockHash=%s", parameters.FullnodeApi, blockHash)
+// getBlocksAtHeightRetry fetches the blocks of a height, with an exponential backoff between the attempts
+func getBlocksAtHeightRetry(ctx context.Context, chain ChainIndex, height int) ([]*Ws, error) {
+	delay := gapFetchRetryDelay
+	for attempt := 1; ; attempt++ {
+		blocks, err := getBlocksAtHeight(chain.From, chain.To, height)
+		if err == nil {
+			return blocks, nil
+		}
 
-	dataBytes, statusCode, err := getHttp(url)
-	if err != nil {
-		return false, fmt.Errorf("failed to query block status: %w", err)
+		if attempt == gapFetchAttempts {
+			return nil, fmt.Errorf("cannot get blocks at height %d: %w", height, err)
+		}
+		log.Printf("Cannot get blocks at height %d for chain %d -> %d, attempt %d, err: %s\n", height, chain.From, chain.To, attempt, err)
+
+		if !sleepCtx(ctx, delay) {
+			return nil, ctx.Err()
+		}
+		delay *= 2
 	}
+}
+
+// queueBlock adds the block to the task queue, the caller waits while the queue is full
+func queueBlock(data *Ws, ch chan Tx) {
+	taskQueue.push(Task{data: data, ch: ch})
+}
 
-	if statusCode != 200 {
-		return false, fmt.Errorf("unexpected status code: %d", statusCode)
+func getTxIdWs(block *Ws, chTxs chan Tx) {
+	if block.Method == block_notify {
+		confirmations.track(block, chTxs)
 	}
+}
 
-	var isMainChain bool
-	err = json.Unmarshal(dataBytes, &isMainChain)
-	if err != nil {
-		return false, fmt.Errorf("failed to parse response: %w", err)
+// countBlockTxs returns the number of transactions to check in a block
+func countBlockTxs(block *Ws) int {
+	cnt := 0
+	for _, tx := range block.Params.Transactions {
+		// no input mean coinbase tx
+		if len(tx.Unsigned.Inputs) > 0 {
+			cnt++
+		}
 	}
 
-	// Return inverse since we want to know if it's a ghost/uncle
-	return !isMainChain, nil
+	return cnt
 }
 
-func getTxStateExplorer(txId string, tx *Transaction) bool {
-	dataBytes, statusCode, err := getHttp(fmt.Sprintf("%s/transactions/%s", parameters.ExplorerApi, txId))
-
-	if err != nil && parameters.debugMode { // do not print error if 404
-		//log.Printf("Error get data from explorer\n%s\n", err)
-		return false
-	}
+// dispatchBlockTxs sends the transactions of the block to the workers, tracked transactions report their
+// alerts to the confirmation tracker
+func dispatchBlockTxs(block *Ws, chTxs chan Tx, tracked bool) {
+	for i, tx := range block.Params.Transactions {
 
-	if statusCode != 404 && statusCode != 200 {
-		fmt.Fprintf(os.Stderr, "Error fullnode explorer getting status: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Status code: %d\n", statusCode)
-		panic(err)
-	}
+		// no input mean coinbase tx
+		if len(tx.Unsigned.Inputs) > 0 {
+			txId := Tx{id: tx.Unsigned.TxID, groupFrom: block.Params.ChainFrom, groupTo: block.Params.ChainTo, height: block.Params.Height, txData: txFromBlock(block, i)}
+			if tracked {
+				txId.blockHash = block.Params.Hash
+			}
 
-	if statusCode == 200 && len(dataBytes) > 0 {
-		err := json.Unmarshal(dataBytes, &tx)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Cannot unmarshall data, err: %s\n", err)
-			panic(err)
+			txQueueMetrics.Inc()
+			chTxs <- txId
 		}
 	}
+}
 
-	if strings.ToLower(tx.Type) == "accepted" {
-		return true
+func isGhostUncle(blockHash string) (bool, error) {
+	isMainChain, err := fullnodeClient.isBlockInMainChain(context.Background(), blockHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to query block status: %w", err)
 	}
 
-	return false
-
+	// Return inverse since we want to know if it's a ghost/uncle
+	return !isMainChain, nil
 }
 
-func getHeightFullnodeState(groupFrom int, groupTo int, txHeight int) bool {
-	url := fmt.Sprintf("https://%s/blockflow/chain-info?fromGroup=%d&toGroup=%d", parameters.FullnodeApi, groupFrom, groupTo)
-	dataBytes, statusCode, err := getHttp(url)
+// getTxStateExplorer fills the transaction from the explorer, returns false while it is not accepted yet or if
+// the explorer is unavailable
+func getTxStateExplorer(txId string, tx *Transaction) bool {
+	explorerTx, found, err := explorerClient.getTransaction(txId)
 	if err != nil {
-		log.Printf("Error getting height\n%s\n", err)
-		return false
-	}
-
-	if statusCode != 200 {
-		log.Printf("Error getting height\n%s\n", err)
+		log.Printf("Error getting tx %s from explorer, err: %s\n", txId, err)
 		return false
 	}
 
-	var heightResp HeightResponse
-	err = json.Unmarshal(dataBytes, &heightResp)
-	if err != nil {
-		log.Printf("Error getting height\n%s\n", err)
+	if !found {
 		return false
 	}
 
-	//log.Printf("block %d,now Height %d\n", txHeight, heightResp.CurrentHeight)
-	//log.Println(heightResp.CurrentHeight - txHeight)
-	return heightResp.CurrentHeight-txHeight >= 10
+	*tx = explorerTx
+	return strings.ToLower(tx.Type) == "accepted"
 }
 
 func getTxData(txId Tx, chMessages chan Message, wId int) {
@@ -447,10 +623,31 @@ func getTxData(txId Tx, chMessages chan Message, wId int) {
 	cntRetry := 0
 	log.Printf("worker %d check %s\n", wId, txId.id)
 
-	for {
+	if txId.blockHash != "" {
+		defer confirmations.txDone(txId.blockHash)
+	}
 
-		if getTxStateExplorer(txId.id, &txData) {
+	// decode from the block payload, the explorer is only used when the fullnode cannot resolve the inputs
+	useExplorer := true
+	if getTxFullnode(txId.txData) {
+		txData = *txId.txData
+		useExplorer = false
+		fullnodeDecodedTxsMetric.Inc()
+	} else if parameters.ExplorerApi == "" {
+		log.Printf("cannot decode tx %s and no explorer configured\n", txId.id)
+		return
+	}
 
+	for useExplorer {
+
+		if getTxStateExplorer(txId.id, &txData) {
+			explorerTxsMetric.Inc()
+			if hasContractOutput(&txData) {
+				if err := resolveContractInputsFullnode(&txData); err != nil {
+					swapsUndecodedMetric.Inc()
+					log.Printf("swaps of tx %s cannot be decoded without its contract inputs, err: %s\n", txId.id, err)
+				}
+			}
 			break
 		}
 
@@ -459,73 +656,88 @@ func getTxData(txId Tx, chMessages chan Message, wId int) {
 		}
 
 		cntRetry++
-		time.Sleep(10 * time.Second)
+		if !sleepCtx(drainCtx, 10*time.Second) {
+			return
+		}
 
 	}
 
-	//log.Printf("Input %+v\n", txData)
-	addressIn := txData.Inputs[0].Address
+	checkTransaction(&txData, txId, chMessages)
+}
 
-	var addressOut string
-	for _, output := range txData.Outputs {
-		addressOut = output.Address
-		txType := output.Type
+// checkTransaction sends an alert for each asset whose net movement is above the ALPH or token trigger
+func checkTransaction(txData *Transaction, txId Tx, chMessages chan Message) {
+	//log.Printf("Input %+v\n", txData)
+	flows, err := computeNetFlows(txData)
+	if err != nil {
+		log.Printf("Cannot compute net flows of tx %s, err: %s\n", txId.id, err)
+		return
+	}
 
-		if strings.ToLower(txType) == "contractoutput" {
-			return
-		}
+	// personal watchlists do not depend on the triggers of the public alerts
+	checkSubscriptions(flows, txId)
 
-		// ignore transactions between these pairs of addresses
-		if expectedOut, exists := ignoredAddressPairs[addressIn]; exists && expectedOut == addressOut {
-			return
+	// transactions with contract outputs are only reported when they are bridge transfers or DEX swaps
+	if hasContractOutput(txData) {
+		if !checkBridge(flows, txId, chMessages) {
+			checkSwaps(txData, flows, txId, chMessages)
 		}
+		return
+	}
 
-		if strings.ToLower(txType) == "assetoutput" {
-			attoStrToFloat, err := strconv.ParseFloat(output.AttoAlphAmount, 32)
-			hintAmountALPH := attoStrToFloat / baseAlph
-
-			if hintAmountALPH >= parameters.MinAmountTrigger {
-
-				if addressIn != addressOut {
-
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "Error when calling BlockflowApi.GetBlockflowBlocks: %v\n", err)
-					}
-					chMessages <- Message{addressIn, addressOut, hintAmountALPH, txId.id, Token{}, txId.groupFrom, txId.groupTo}
-					notificationQueueMetric.Inc()
+	// ignore transactions between these pairs of addresses
+	for _, flow := range flows {
+		for _, sender := range flow.senders {
+			for _, recipient := range flow.recipients {
+				if expectedOut, exists := ignoredAddressPairs[sender.address]; exists && expectedOut == recipient.address {
+					return
 				}
 			}
+		}
+	}
 
-			if len(output.Tokens) > 0 {
-				for _, token := range output.Tokens {
+	for _, flow := range flows {
+		if len(flow.senders) == 0 || len(flow.recipients) == 0 {
+			continue
+		}
 
-					if amountTrigger, found := trackTokens[token.ID]; found {
-						tokenData := searchTokenData(token.ID)
-						if tokenData.Name == "" {
-							log.Printf("error cannot found info for token %s", token.ID)
-						}
+		amountFloat, _ := new(big.Float).SetInt(flow.amount).Float64()
+		msg := Message{
+			from:          flow.senders[0].address,
+			to:            flow.recipients[0].address,
+			fromAddresses: addresses(flow.senders),
+			toAddresses:   addresses(flow.recipients),
+			txId:          txId.id,
+			groupFrom:     txId.groupFrom,
+			groupTo:       txId.groupTo,
+			blockHash:     txId.blockHash,
+			height:        txId.height,
+			status:        txId.status,
+		}
 
-						tokenAmount, err := strconv.ParseFloat(token.Amount, 64)
-						if err != nil {
-							log.Printf("Cannot parse ayin amount, err: %s\n", err)
-							return
-						}
+		if flow.tokenId == alphFlowId {
+			hintAmountALPH := amountFloat / baseAlph
+			if hintAmountALPH >= parameters.MinAmountTrigger {
+				msg.amountChain = hintAmountALPH
+				emitMessage(msg, chMessages)
+			}
+			continue
+		}
 
-						decimal := float64(tokenData.Decimals)
-						amount := tokenAmount / math.Pow(10.0, decimal)
+		if amountTrigger, found := trackTokens[flow.tokenId]; found {
+			tokenData := searchTokenData(flow.tokenId)
+			if tokenData.Name == "" {
+				log.Printf("error cannot found info for token %s", flow.tokenId)
+			}
 
-						if amount >= float64(amountTrigger) {
-							if addressIn != addressOut {
-								chMessages <- Message{addressIn, addressOut, tokenAmount, txId.id, tokenData, txId.groupFrom, txId.groupTo}
-								notificationQueueMetric.Inc()
-							}
-						}
-					}
+			decimal := float64(tokenData.Decimals)
+			amount := amountFloat / math.Pow(10.0, decimal)
 
-				}
+			if amount >= float64(amountTrigger) {
+				msg.amountChain = amountFloat
+				msg.tokenData = tokenData
+				emitMessage(msg, chMessages)
 			}
-
 		}
 	}
-
 }
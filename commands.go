@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+)
+
+const (
+	// long polling timeout of getUpdates, in seconds
+	commandsPollingTimeout = 30
+	commandsQueryTimeout   = 15 * time.Second
+	commandsMaxResults     = 10
+	commandsDefaultWindow  = 24 * time.Hour
+)
+
+const commandsHelp = `/price [symbol] - price of ALPH or a tracked token
+/whales [24h] - largest alerts of the window, up to 7d
+/address &lt;address&gt; - label and recent alerts of an address
+/tx &lt;id&gt; - state of a transaction
+/thresholds - amounts triggering the alerts
+
+In a private chat:
+/watch &lt;address&gt; - receive the transactions of an address
+/unwatch &lt;address&gt;
+/token &lt;symbol&gt; &lt;min&gt; - receive the transfers of a token from an amount
+/untoken &lt;symbol&gt;
+/watchlist - your addresses and tokens`
+
+// runTelegramCommands answers the commands sent to the bot until the context is done
+func runTelegramCommands(ctx context.Context, bot *telego.Bot) {
+	updates, err := bot.UpdatesViaLongPolling(&telego.GetUpdatesParams{
+		Timeout:        commandsPollingTimeout,
+		AllowedUpdates: []string{"message"},
+	}, telego.WithLongPollingContext(ctx))
+	if err != nil {
+		log.Printf("cannot start telegram long polling, err: %s\n", err)
+		return
+	}
+	defer bot.StopLongPolling()
+
+	log.Printf("Listening to telegram commands\n")
+	for update := range updates {
+		if update.Message == nil || !strings.HasPrefix(update.Message.Text, "/") {
+			continue
+		}
+
+		handleCommand(ctx, bot, update.Message)
+	}
+}
+
+func handleCommand(ctx context.Context, bot *telego.Bot, message *telego.Message) {
+	fields := strings.Fields(message.Text)
+	// commands sent in groups are suffixed with the bot name, e.g. /price@WhalesBot
+	command := strings.ToLower(strings.SplitN(fields[0], "@", 2)[0])
+	args := fields[1:]
+
+	ctx, cancel := context.WithTimeout(ctx, commandsQueryTimeout)
+	defer cancel()
+
+	var reply string
+	switch command {
+	case "/start", "/help":
+		reply = commandsHelp
+	case "/price":
+		reply = priceCommand(args)
+	case "/whales":
+		reply = whalesCommand(args)
+	case "/address":
+		reply = addressCommand(ctx, args)
+	case "/tx":
+		reply = txCommand(args)
+	case "/thresholds":
+		reply = thresholdsCommand()
+	case "/watch", "/unwatch", "/token", "/untoken", "/watchlist":
+		reply = watchlistCommands(command, message, args)
+	default:
+		return
+	}
+	commandsMetric.WithLabelValues(strings.TrimPrefix(command, "/")).Inc()
+
+	_, err := bot.SendMessage(&telego.SendMessageParams{
+		ChatID:             telego.ChatID{ID: message.Chat.ID},
+		Text:               truncateText(reply, 4096),
+		ParseMode:          telego.ModeHTML,
+		LinkPreviewOptions: &telego.LinkPreviewOptions{IsDisabled: true},
+		ReplyParameters:    &telego.ReplyParameters{MessageID: message.MessageID, AllowSendingWithoutReply: true},
+	})
+	if err != nil {
+		log.Printf("cannot reply to command %s, err: %s\n", command, err)
+	}
+}
+
+// watchlistCommands edits the watchlist of the user, only in private chats to keep groups quiet
+func watchlistCommands(command string, message *telego.Message, args []string) string {
+	if message.Chat.Type != telego.ChatTypePrivate {
+		return "Send me this command in a private chat"
+	}
+
+	chatId := message.Chat.ID
+	switch command {
+	case "/watch":
+		return watchCommand(chatId, args)
+	case "/unwatch":
+		return unwatchCommand(chatId, args)
+	case "/token":
+		return tokenCommand(chatId, args)
+	case "/untoken":
+		return untokenCommand(chatId, args)
+	}
+
+	return watchlistCommand(chatId)
+}
+
+func priceCommand(args []string) string {
+	if len(args) == 0 || strings.EqualFold(args[0], "ALPH") {
+		if coinGeckoPrice <= 0 {
+			return "ALPH price is not known yet"
+		}
+		return fmt.Sprintf("$ALPH: %.4f USDT", coinGeckoPrice)
+	}
+
+	symbol := strings.TrimPrefix(args[0], "$")
+	for _, token := range Tokens.Tokens {
+		if !strings.EqualFold(token.Symbol, symbol) {
+			continue
+		}
+
+		price, ok := tokenUsdPrice(token.ID, token.Symbol)
+		if !ok {
+			return fmt.Sprintf("No recent DEX price for $%s", html.EscapeString(token.Symbol))
+		}
+		return fmt.Sprintf("$%s: %.6f USDT", html.EscapeString(token.Symbol), price)
+	}
+
+	return fmt.Sprintf("Unknown token %s", html.EscapeString(symbol))
+}
+
+func whalesCommand(args []string) string {
+	window := commandsDefaultWindow
+	if len(args) > 0 {
+		parsed, err := parseWindow(args[0])
+		if err != nil || parsed <= 0 || parsed > alertHistoryMaxAge {
+			return "Usage: /whales [window], e.g. 1h, 24h or 7d"
+		}
+		window = parsed
+	}
+
+	records := alertHistory.since(window)
+	if len(records) == 0 {
+		return fmt.Sprintf("No alert in the last %s", formatWindow(window))
+	}
+
+	// largest first, alerts without price last
+	sort.SliceStable(records, func(i, j int) bool {
+		usdI, _ := records[i].msg.usdValue()
+		usdJ, _ := records[j].msg.usdValue()
+		return usdI > usdJ
+	})
+
+	text := fmt.Sprintf("🐋 %d alerts in the last %s\n\n", len(records), formatWindow(window))
+	for i, record := range records {
+		if i == commandsMaxResults {
+			break
+		}
+		text += alertSummary(record) + "\n"
+	}
+
+	return text
+}
+
+func addressCommand(ctx context.Context, args []string) string {
+	if len(args) == 0 {
+		return "Usage: /address &lt;address&gt;"
+	}
+	address := args[0]
+	// the address ends up in the fullnode path
+	if !isValidAddress(address) {
+		return "Invalid address"
+	}
+
+	text := fmt.Sprintf("<a href='%s/#/addresses/%s'>%s</a>\n", parameters.FrontendExplorerUrl, html.EscapeString(address), html.EscapeString(address))
+	if wallet := getAddressName(&address); wallet.Name != "" {
+		text += fmt.Sprintf("Label: %s\n", html.EscapeString(wallet.Name))
+		if wallet.ExchangeName != "" {
+			text += fmt.Sprintf("Exchange: %s\n", html.EscapeString(wallet.ExchangeName))
+		}
+	}
+
+	balance, err := fullnodeClient.balance(ctx, address)
+	if err != nil {
+		log.Printf("cannot get balance of %s, err: %s\n", address, err)
+	} else {
+		text += fmt.Sprintf("Balance: %s\n", html.EscapeString(balance.BalanceHint))
+	}
+
+	records := alertHistory.byAddress(address, commandsMaxResults)
+	if len(records) == 0 {
+		return text + "\nNo recent alert"
+	}
+
+	text += "\nRecent alerts:\n"
+	for _, record := range records {
+		text += alertSummary(record) + "\n"
+	}
+
+	return text
+}
+
+func txCommand(args []string) string {
+	if len(args) == 0 {
+		return "Usage: /tx &lt;id&gt;"
+	}
+	txId := args[0]
+	// the id ends up in the explorer path
+	if !isValidTxId(txId) {
+		return "Invalid transaction id, expected 64 hex characters"
+	}
+	link := fmt.Sprintf("<a href='%s/#/transactions/%s'>TX link</a>", parameters.FrontendExplorerUrl, html.EscapeString(txId))
+
+	var text string
+	for _, record := range alertHistory.byTx(txId) {
+		text += "Alert: " + alertSummary(record) + "\n"
+	}
+
+	tx, found, err := explorerClient.getTransaction(txId)
+	if err != nil {
+		log.Printf("cannot get tx %s, err: %s\n", txId, err)
+		return text + "Cannot query the explorer, try again later"
+	}
+	if !found {
+		return text + "Transaction not found"
+	}
+
+	state := "accepted"
+	if !strings.EqualFold(tx.Type, "accepted") {
+		state = strings.ToLower(tx.Type)
+	} else if !tx.ScriptExecutionOk {
+		state = "accepted, script failed"
+	}
+
+	return text + fmt.Sprintf("Transaction %s\n%s, %d inputs, %d outputs\n%s", html.EscapeString(state), time.UnixMilli(tx.Timestamp).UTC().Format(time.RFC1123), len(tx.Inputs), len(tx.Outputs), link)
+}
+
+func thresholdsCommand() string {
+	text := fmt.Sprintf("$ALPH transfers: %s\n", Amount{Value: parameters.MinAmountTrigger, Symbol: "$ALPH"}.formatHuman())
+	text += fmt.Sprintf("DEX swaps: %s\n", Amount{Value: parameters.MinAmountSwapTrigger, Symbol: "$ALPH"}.formatHuman())
+	text += fmt.Sprintf("Bridge transfers: %s\n", Amount{Value: parameters.MinAmountBridgeTriggerUsd, Symbol: "USDT"}.formatHuman())
+	text += fmt.Sprintf("CEX trades: %s\n", Amount{Value: parameters.MinAmountCexTriggerUsd, Symbol: "USDT"}.formatHuman())
+
+	var tokenLines []string
+	for tokenId, amount := range trackTokens {
+		symbol := searchTokenData(tokenId).Symbol
+		if symbol == "" {
+			continue
+		}
+		tokenLines = append(tokenLines, fmt.Sprintf("$%s transfers: %s\n", html.EscapeString(symbol), Amount{Value: amount, Symbol: "$" + symbol}.formatHuman()))
+	}
+	sort.Strings(tokenLines)
+	text += strings.Join(tokenLines, "")
+
+	text += fmt.Sprintf("\nAlerts are confirmed after %d blocks", parameters.ConfirmationDepth)
+	for _, severity := range parameters.SeverityDepths {
+		if severity.depth < parameters.ConfirmationDepth {
+			text += fmt.Sprintf("\nAlerts %gx above the trigger after %d blocks", severity.minRatio, severity.depth)
+		}
+	}
+
+	return text
+}
+
+// isValidTxId checks that the id is a 32 bytes hex hash
+func isValidTxId(txId string) bool {
+	if len(txId) != 64 {
+		return false
+	}
+
+	_, err := hex.DecodeString(txId)
+	return err == nil
+}
+
+// alertSummary is a one line description of the alert
+func alertSummary(record AlertRecord) string {
+	_, symbol, amount := record.msg.asset()
+
+	text := fmt.Sprintf("%s ", record.time.UTC().Format("Jan 02 15:04"))
+	switch {
+	case record.msg.swap != nil:
+		side, swapSymbol := record.msg.swap.direction()
+		text += fmt.Sprintf("%s $%s", strings.ToLower(side), swapSymbol)
+	case record.msg.event != nil:
+		text += html.EscapeString(record.msg.event.name)
+		if symbol != "" {
+			text += " " + Amount{Value: amount, Symbol: "$" + symbol}.formatHuman()
+		}
+	default:
+		text += Amount{Value: amount, Symbol: "$" + symbol}.formatHuman()
+	}
+
+	if usd, ok := record.msg.usdValue(); ok {
+		text += fmt.Sprintf(" (%s)", Amount{Value: usd, Symbol: "USDT"}.formatHuman())
+	}
+
+	return text + fmt.Sprintf(" <a href='%s/#/transactions/%s'>tx</a>", parameters.FrontendExplorerUrl, record.msg.txId)
+}
+
+// parseWindow parses a duration, with d for days, e.g. "24h" or "7d"
+func parseWindow(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(value)
+}
+
+func formatWindow(window time.Duration) string {
+	if window%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", window/(24*time.Hour))
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(window.String(), "0s"), "0m")
+}
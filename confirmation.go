@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how often the height of chains with tracked blocks is checked
+const confirmationPollInterval = 5 * time.Second
+
+// default depth before a block is considered confirmed
+const defaultConfirmationDepth = 10
+
+type AlertStatus int
+
+const (
+	statusConfirmed AlertStatus = iota
+	statusUnconfirmed
+	statusOrphaned
+	statusPending
+)
+
+// SeverityDepth is the depth to wait for alerts with an amount at least minRatio times the trigger
+type SeverityDepth struct {
+	minRatio float64
+	depth    int
+}
+
+type deferredMessage struct {
+	msg   Message
+	depth int
+}
+
+type trackedBlock struct {
+	block      *Ws
+	chain      ChainIndex
+	addedAt    time.Time
+	dispatched bool
+	pendingTxs int
+	depth      int
+	deferred   []deferredMessage
+	sent       []Message // alerts sent before the block was confirmed
+	chTxs      chan Tx
+	chMessages chan Message
+}
+
+// ConfirmationTracker holds blocks until they reach the depth required by their alerts
+type ConfirmationTracker struct {
+	mu       sync.Mutex
+	blocks   map[string]*trackedBlock
+	orphaned map[string]time.Time
+}
+
+func NewConfirmationTracker() *ConfirmationTracker {
+	return &ConfirmationTracker{
+		blocks:   make(map[string]*trackedBlock),
+		orphaned: make(map[string]time.Time),
+	}
+}
+
+var confirmations = NewConfirmationTracker()
+
+// parseSeverityDepths parses "100:0,10:3", alerts 100 times above the trigger are sent without waiting,
+// alerts 10 times above the trigger are sent after 3 blocks
+func parseSeverityDepths(value string) []SeverityDepth {
+	var severities []SeverityDepth
+	if value == "" {
+		return severities
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) != 2 {
+			log.Printf("cannot parse confirmation depth %s\n", item)
+			continue
+		}
+
+		minRatio, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			log.Printf("cannot parse confirmation depth ratio %s, err: %s\n", item, err)
+			continue
+		}
+
+		depth, err := strconv.Atoi(parts[1])
+		if err != nil {
+			log.Printf("cannot parse confirmation depth %s, err: %s\n", item, err)
+			continue
+		}
+
+		severities = append(severities, SeverityDepth{minRatio, depth})
+	}
+
+	// highest severity first
+	sort.Slice(severities, func(i, j int) bool { return severities[i].minRatio > severities[j].minRatio })
+
+	return severities
+}
+
+// depthFor returns the depth to wait before sending the alert, depending on how far above the trigger it is
+func depthFor(msg Message) int {
+	amount, _ := msg.humanAmount()
+
+	trigger := parameters.MinAmountTrigger
+	if msg.tokenData.Name != "" {
+		trigger = trackTokens[msg.tokenData.ID]
+	}
+
+	if trigger > 0 {
+		ratio := amount / trigger
+		for _, severity := range parameters.SeverityDepths {
+			if ratio >= severity.minRatio && severity.depth < parameters.ConfirmationDepth {
+				return severity.depth
+			}
+		}
+	}
+
+	return parameters.ConfirmationDepth
+}
+
+// minDepth is the depth at which transactions of a block start to be checked
+func minDepth() int {
+	depth := parameters.ConfirmationDepth
+	for _, severity := range parameters.SeverityDepths {
+		if severity.depth < depth {
+			depth = severity.depth
+		}
+	}
+
+	return depth
+}
+
+// track adds a block received from the fullnode, its transactions are checked once deep enough
+func (ct *ConfirmationTracker) track(block *Ws, chTxs chan Tx) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	if _, ok := ct.blocks[block.Params.Hash]; ok {
+		return
+	}
+
+	ct.blocks[block.Params.Hash] = &trackedBlock{
+		block:   block,
+		chain:   ChainIndex{block.Params.ChainFrom, block.Params.ChainTo},
+		addedAt: time.Now(),
+		chTxs:   chTxs,
+	}
+	trackedBlocksMetric.Set(float64(len(ct.blocks)))
+}
+
+// submit sends the alert now if the block is deep enough for it, otherwise keeps it until it is
+func (ct *ConfirmationTracker) submit(msg Message, chMessages chan Message) {
+	ct.mu.Lock()
+
+	if _, ok := ct.orphaned[msg.blockHash]; ok {
+		ct.mu.Unlock()
+		log.Printf("Drop alert for tx %s, block %s is orphaned\n", msg.txId, msg.blockHash)
+		return
+	}
+
+	b, ok := ct.blocks[msg.blockHash]
+	if !ok {
+		// block is not tracked anymore
+		ct.mu.Unlock()
+		pushMessage(msg, chMessages)
+		return
+	}
+
+	b.chMessages = chMessages
+	depth := depthFor(msg)
+	if depth > b.depth {
+		b.deferred = append(b.deferred, deferredMessage{msg, depth})
+		ct.mu.Unlock()
+		return
+	}
+
+	if b.depth < parameters.ConfirmationDepth {
+		msg.status = statusUnconfirmed
+		b.sent = append(b.sent, msg)
+	}
+	ct.mu.Unlock()
+
+	pushMessage(msg, chMessages)
+}
+
+// txDone records that a transaction of the block has been checked
+func (ct *ConfirmationTracker) txDone(blockHash string) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	if b, ok := ct.blocks[blockHash]; ok {
+		b.pendingTxs--
+	}
+}
+
+func (ct *ConfirmationTracker) run(ctx context.Context) {
+	ticker := time.NewTicker(confirmationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ct.tick()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (ct *ConfirmationTracker) tick() {
+	ct.mu.Lock()
+	blocks := make([]*trackedBlock, 0, len(ct.blocks))
+	for _, b := range ct.blocks {
+		blocks = append(blocks, b)
+	}
+
+	for hash, orphanedAt := range ct.orphaned {
+		if time.Since(orphanedAt) > taskTimeout {
+			delete(ct.orphaned, hash)
+		}
+	}
+	ct.mu.Unlock()
+
+	// one height query per chain
+	heights := make(map[ChainIndex]int)
+	for _, b := range blocks {
+		height, ok := heights[b.chain]
+		if !ok {
+			currentHeight, err := getChainHeight(b.chain.From, b.chain.To)
+			if err != nil {
+				log.Printf("Error getting height\n%s\n", err)
+				currentHeight = -1
+			}
+			heights[b.chain] = currentHeight
+			height = currentHeight
+		}
+
+		if height < 0 {
+			if time.Since(b.addedAt) > taskTimeout {
+				ct.finalize(b, false)
+			}
+			continue
+		}
+
+		ct.checkBlock(b, height-b.block.Params.Height)
+	}
+}
+
+func (ct *ConfirmationTracker) checkBlock(b *trackedBlock, depth int) {
+	ct.mu.Lock()
+	b.depth = depth
+	needDispatch := !b.dispatched && depth >= minDepth()
+	final := depth >= parameters.ConfirmationDepth && (b.dispatched || needDispatch) && b.pendingTxs == 0
+	expired := time.Since(b.addedAt) > taskTimeout
+	dueDeferred := false
+	for _, deferred := range b.deferred {
+		if deferred.depth <= depth {
+			dueDeferred = true
+		}
+	}
+	ct.mu.Unlock()
+
+	if !needDispatch && !dueDeferred && !final && !expired {
+		return
+	}
+
+	// re-validate main chain membership before anything is sent
+	isGhost, err := isGhostUncle(b.block.Params.Hash)
+	if err != nil {
+		log.Printf("Error checking if block is ghost uncle: %v", err)
+		if expired {
+			ct.finalize(b, false)
+		}
+		return
+	}
+
+	if isGhost {
+		ct.orphan(b)
+		return
+	}
+
+	if needDispatch {
+		ct.dispatch(b)
+
+		// transactions of the block still have to be checked
+		ct.mu.Lock()
+		final = final && b.pendingTxs == 0
+		ct.mu.Unlock()
+	}
+
+	if final {
+		ct.finalize(b, true)
+		return
+	}
+
+	if expired {
+		ct.expire(b)
+		return
+	}
+
+	ct.releaseDeferred(b, depth)
+}
+
+// dispatch sends the transactions of the block to the workers
+func (ct *ConfirmationTracker) dispatch(b *trackedBlock) {
+	ct.mu.Lock()
+	b.dispatched = true
+	b.pendingTxs = countBlockTxs(b.block)
+	ct.mu.Unlock()
+
+	dispatchBlockTxs(b.block, b.chTxs, true)
+}
+
+// releaseDeferred sends the alerts that were waiting for the block to reach their depth
+func (ct *ConfirmationTracker) releaseDeferred(b *trackedBlock, depth int) {
+	ct.mu.Lock()
+	var toSend []Message
+	var remaining []deferredMessage
+	for _, deferred := range b.deferred {
+		if deferred.depth > depth {
+			remaining = append(remaining, deferred)
+			continue
+		}
+
+		msg := deferred.msg
+		if depth < parameters.ConfirmationDepth {
+			msg.status = statusUnconfirmed
+			b.sent = append(b.sent, msg)
+		}
+		toSend = append(toSend, msg)
+	}
+	b.deferred = remaining
+	chMessages := b.chMessages
+	ct.mu.Unlock()
+
+	for _, msg := range toSend {
+		pushMessage(msg, chMessages)
+	}
+}
+
+// finalize sends the remaining alerts and a confirmed status for alerts sent before confirmation
+func (ct *ConfirmationTracker) finalize(b *trackedBlock, inMainChain bool) {
+	ct.mu.Lock()
+	var toSend []Message
+	if inMainChain {
+		for _, deferred := range b.deferred {
+			toSend = append(toSend, deferred.msg)
+		}
+
+		for _, msg := range b.sent {
+			msg.status = statusConfirmed
+			msg.followUp = true
+			toSend = append(toSend, msg)
+		}
+	} else {
+		log.Printf("Give up waiting for block %s confirmation, %d alerts dropped\n", b.block.Params.Hash, len(b.deferred))
+	}
+	chMessages := b.chMessages
+	ct.remove(b)
+	ct.mu.Unlock()
+
+	for _, msg := range toSend {
+		pushMessage(msg, chMessages)
+	}
+}
+
+// expire stops waiting for a block of the main chain which did not reach the confirmation depth in time, the
+// remaining alerts are sent unconfirmed and the alerts sent before are never reported as confirmed
+func (ct *ConfirmationTracker) expire(b *trackedBlock) {
+	ct.mu.Lock()
+	var toSend []Message
+	for _, deferred := range b.deferred {
+		msg := deferred.msg
+		msg.status = statusUnconfirmed
+		toSend = append(toSend, msg)
+	}
+	log.Printf("Give up waiting for block %s confirmation at depth %d, %d alerts sent unconfirmed\n", b.block.Params.Hash, b.depth, len(toSend))
+	chMessages := b.chMessages
+	ct.remove(b)
+	ct.mu.Unlock()
+
+	for _, msg := range toSend {
+		pushMessage(msg, chMessages)
+	}
+}
+
+// orphan drops the alerts of a block out of the main chain and sends an orphaned status for the ones already sent
+func (ct *ConfirmationTracker) orphan(b *trackedBlock) {
+	log.Printf("Block %s is a ghost uncle.", b.block.Params.Hash)
+	orphanedBlocksMetric.Inc()
+
+	ct.mu.Lock()
+	var toSend []Message
+	for _, msg := range b.sent {
+		msg.status = statusOrphaned
+		msg.followUp = true
+		toSend = append(toSend, msg)
+	}
+	chMessages := b.chMessages
+	ct.orphaned[b.block.Params.Hash] = time.Now()
+	ct.remove(b)
+	ct.mu.Unlock()
+
+	for _, msg := range toSend {
+		pushMessage(msg, chMessages)
+	}
+}
+
+// remove stops tracking the block, must be called with the lock held
+func (ct *ConfirmationTracker) remove(b *trackedBlock) {
+	delete(ct.blocks, b.block.Params.Hash)
+	trackedBlocksMetric.Set(float64(len(ct.blocks)))
+	chainCursor.markProcessed(b.chain.From, b.chain.To, b.block.Params.Height)
+}
+
+func pushMessage(msg Message, chMessages chan Message) {
+	if msg.status == statusPending {
+		pendingAlerts.add(msg)
+	} else if !msg.followUp && pendingAlerts.link(msg) {
+		// already alerted while in the mempool, only send the status update
+		msg.followUp = true
+	}
+
+	chMessages <- msg
+	notificationQueueMetric.Inc()
+}
+
+// emitMessage sends an alert, alerts of live blocks go through the confirmation tracker
+func emitMessage(msg Message, chMessages chan Message) {
+	if msg.blockHash != "" {
+		confirmations.submit(msg, chMessages)
+		return
+	}
+
+	pushMessage(msg, chMessages)
+}
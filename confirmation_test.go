@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+)
+
+type sentStatus struct {
+	status   AlertStatus
+	followUp bool
+}
+
+func TestConfirmationTracker(t *testing.T) {
+	saved := parameters
+	t.Cleanup(func() { parameters = saved })
+	parameters.ConfirmationDepth = 10
+	parameters.MinAmountTrigger = 1000
+	parameters.SeverityDepths = parseSeverityDepths("100:0,10:3")
+
+	tests := []struct {
+		name string
+		// amount of the alert, in ALPH
+		amount float64
+		// depth the block reaches before the end, 0 to skip
+		releaseDepth int
+		end          string
+		want         []sentStatus
+	}{
+		{
+			name:   "severe alert is sent unconfirmed then confirmed",
+			amount: 200000,
+			end:    "finalize",
+			want:   []sentStatus{{statusUnconfirmed, false}, {statusConfirmed, true}},
+		},
+		{
+			name:         "deferred alert is sent at its depth",
+			amount:       20000,
+			releaseDepth: 3,
+			end:          "finalize",
+			want:         []sentStatus{{statusUnconfirmed, false}, {statusConfirmed, true}},
+		},
+		{
+			name:   "alert waiting for the confirmation depth is sent confirmed",
+			amount: 2000,
+			end:    "finalize",
+			want:   []sentStatus{{statusConfirmed, false}},
+		},
+		{
+			name:   "expired block is never reported confirmed",
+			amount: 200000,
+			end:    "expire",
+			want:   []sentStatus{{statusUnconfirmed, false}},
+		},
+		{
+			name:   "expired block sends the deferred alerts unconfirmed",
+			amount: 2000,
+			end:    "expire",
+			want:   []sentStatus{{statusUnconfirmed, false}},
+		},
+		{
+			name:   "orphaned block reports the alerts sent before",
+			amount: 200000,
+			end:    "orphan",
+			want:   []sentStatus{{statusUnconfirmed, false}, {statusOrphaned, true}},
+		},
+		{
+			name:   "orphaned block drops the deferred alerts",
+			amount: 2000,
+			end:    "orphan",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ct := NewConfirmationTracker()
+			block := &Ws{}
+			block.Params.Hash = "block-" + test.name
+			block.Params.Height = 100
+			ct.track(block, nil)
+			b := ct.blocks[block.Params.Hash]
+
+			chMessages := make(chan Message, 10)
+			ct.submit(Message{txId: "tx-" + test.name, amountChain: test.amount, blockHash: block.Params.Hash}, chMessages)
+
+			if test.releaseDepth > 0 {
+				b.depth = test.releaseDepth
+				ct.releaseDeferred(b, test.releaseDepth)
+			}
+
+			switch test.end {
+			case "finalize":
+				ct.finalize(b, true)
+			case "expire":
+				ct.expire(b)
+			case "orphan":
+				ct.orphan(b)
+			}
+			close(chMessages)
+
+			var got []sentStatus
+			for msg := range chMessages {
+				got = append(got, sentStatus{msg.status, msg.followUp})
+			}
+
+			if len(got) != len(test.want) {
+				t.Fatalf("sent %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("sent %v, want %v", got, test.want)
+					break
+				}
+			}
+
+			if _, ok := ct.blocks[block.Params.Hash]; ok {
+				t.Error("block still tracked after the end")
+			}
+		})
+	}
+}
+
+func TestParseSeverityDepths(t *testing.T) {
+	severities := parseSeverityDepths("10:3, 100:0,bad,5:x")
+	want := []SeverityDepth{{100, 0}, {10, 3}}
+	if len(severities) != len(want) {
+		t.Fatalf("parseSeverityDepths() = %v, want %v", severities, want)
+	}
+	for i := range want {
+		if severities[i] != want[i] {
+			t.Errorf("parseSeverityDepths() = %v, want %v", severities, want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// how long a block hash is remembered to avoid processing it twice
+const seenBlockTtl = 1 * time.Hour
+
+// how often the cursor is written on disk
+const cursorSaveInterval = 10 * time.Second
+
+type ChainIndex struct {
+	From int
+	To   int
+}
+
+type CursorEntry struct {
+	ChainFrom int `json:"chainFrom"`
+	ChainTo   int `json:"chainTo"`
+	Height    int `json:"height"`
+}
+
+type CursorFile struct {
+	UpdatedAt int64         `json:"updatedAt"`
+	Chains    []CursorEntry `json:"chains"`
+}
+
+// ChainCursor keeps track of the heights processed on each chain
+type ChainCursor struct {
+	mu        sync.Mutex
+	processed map[ChainIndex]int
+	pending   map[ChainIndex]map[int]int
+	// first height of the chain not replayed yet, live blocks cannot move the saved height past it
+	gaps  map[ChainIndex]int
+	seen  map[string]time.Time
+	dirty bool
+}
+
+func NewChainCursor() *ChainCursor {
+	return &ChainCursor{
+		processed: make(map[ChainIndex]int),
+		pending:   make(map[ChainIndex]map[int]int),
+		gaps:      make(map[ChainIndex]int),
+		seen:      make(map[string]time.Time),
+	}
+}
+
+// markSeen records the block and returns false if it was already seen
+func (c *ChainCursor) markSeen(blockHash string, groupFrom int, groupTo int, height int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if seenAt, ok := c.seen[blockHash]; ok && now.Sub(seenAt) < seenBlockTtl {
+		return false
+	}
+	c.seen[blockHash] = now
+
+	chain := ChainIndex{groupFrom, groupTo}
+	if c.pending[chain] == nil {
+		c.pending[chain] = make(map[int]int)
+	}
+	c.pending[chain][height]++
+	c.dirty = true
+
+	return true
+}
+
+// pruneSeen forgets the blocks seen before the TTL
+func (c *ChainCursor) pruneSeen() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for hash, seenAt := range c.seen {
+		if now.Sub(seenAt) >= seenBlockTtl {
+			delete(c.seen, hash)
+		}
+	}
+}
+
+// markProcessed records that a block seen before is fully processed
+func (c *ChainCursor) markProcessed(groupFrom int, groupTo int, height int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	chain := ChainIndex{groupFrom, groupTo}
+	if pending, ok := c.pending[chain]; ok && pending[height] > 0 {
+		pending[height]--
+		if pending[height] == 0 {
+			delete(pending, height)
+		}
+	}
+
+	if height > c.processed[chain] {
+		c.processed[chain] = height
+	}
+	c.dirty = true
+}
+
+// startResume returns the safe height of each chain, taken before listening to live blocks which would hide a
+// gap. The heights above are kept as a gap until the replay of the chain is done, so a restart in the middle of
+// the replay resumes from the same heights
+func (c *ChainCursor) startResume() map[ChainIndex]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	heights := make(map[ChainIndex]int)
+	for groupFrom := 0; groupFrom < groupNum; groupFrom++ {
+		for groupTo := 0; groupTo < groupNum; groupTo++ {
+			chain := ChainIndex{groupFrom, groupTo}
+			if height, ok := c.safeHeight(chain); ok {
+				heights[chain] = height
+				c.gaps[chain] = height + 1
+			}
+		}
+	}
+
+	return heights
+}
+
+// advanceGap records that the blocks of the gap of the chain below nextHeight are queued
+func (c *ChainCursor) advanceGap(chain ChainIndex, nextHeight int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.gaps[chain]; ok {
+		c.gaps[chain] = nextHeight
+		c.dirty = true
+	}
+}
+
+// closeGap records that every block of the gap of the chain is queued, pending blocks still hold the height
+func (c *ChainCursor) closeGap(chain ChainIndex) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.gaps, chain)
+	c.dirty = true
+}
+
+// safeHeight returns the height under which every block of the chain is processed
+func (c *ChainCursor) safeHeight(chain ChainIndex) (int, bool) {
+	height, ok := c.processed[chain]
+
+	if gap, hasGap := c.gaps[chain]; hasGap && (!ok || gap-1 < height) {
+		height = gap - 1
+		ok = true
+	}
+
+	for pendingHeight := range c.pending[chain] {
+		if !ok || pendingHeight-1 < height {
+			height = pendingHeight - 1
+			ok = true
+		}
+	}
+
+	return height, ok
+}
+
+// load restores the cursor saved on disk, a missing file is not an error
+func (c *ChainCursor) load(path string) error {
+	dataBytes, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read cursor file: %w", err)
+	}
+
+	var cursorFile CursorFile
+	err = json.Unmarshal(dataBytes, &cursorFile)
+	if err != nil {
+		return fmt.Errorf("cannot parse cursor file: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, entry := range cursorFile.Chains {
+		chain := ChainIndex{entry.ChainFrom, entry.ChainTo}
+		c.processed[chain] = entry.Height
+	}
+
+	return nil
+}
+
+// save writes the cursor on disk, the file is replaced atomically to survive a crash
+func (c *ChainCursor) save(path string) error {
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+
+	cursorFile := CursorFile{UpdatedAt: time.Now().Unix()}
+	for groupFrom := 0; groupFrom < groupNum; groupFrom++ {
+		for groupTo := 0; groupTo < groupNum; groupTo++ {
+			if height, ok := c.safeHeight(ChainIndex{groupFrom, groupTo}); ok {
+				cursorFile.Chains = append(cursorFile.Chains, CursorEntry{groupFrom, groupTo, height})
+			}
+		}
+	}
+	c.dirty = false
+	c.mu.Unlock()
+
+	err := writeJsonFile(path, cursorFile)
+	if err != nil {
+		// try again at the next save
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+	}
+
+	return err
+}
+
+// writeJsonFile replaces the file atomically with the serialized value
+func writeJsonFile(path string, value any) error {
+	dataBytes, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot serialize %s: %w", path, err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("cannot create %s: %w", path, err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(dataBytes); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("cannot write %s: %w", path, err)
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("cannot sync %s: %w", path, err)
+	}
+	tmpFile.Close()
+
+	return os.Rename(tmpFile.Name(), path)
+}
+
+// saveCursorLoop periodically writes the cursor on disk and forgets the old blocks
+func saveCursorLoop(ctx context.Context, path string) {
+	ticker := time.NewTicker(cursorSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			chainCursor.pruneSeen()
+			if err := chainCursor.save(path); err != nil {
+				log.Printf("Error saving cursor, err: %s\n", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+var chainCursor = NewChainCursor()
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+type cursorBlock struct {
+	hash      string
+	height    int
+	processed bool
+}
+
+func TestChainCursorSafeHeight(t *testing.T) {
+	chain := ChainIndex{0, 1}
+
+	tests := []struct {
+		name    string
+		saved   int
+		blocks  []cursorBlock
+		resume  bool
+		advance int
+		closed  bool
+		want    int
+		wantAny bool
+	}{
+		{
+			name:    "nothing seen",
+			wantAny: false,
+		},
+		{
+			name:    "processed in order",
+			blocks:  []cursorBlock{{"a", 10, true}, {"b", 11, true}},
+			want:    11,
+			wantAny: true,
+		},
+		{
+			name:    "pending block holds the height",
+			blocks:  []cursorBlock{{"a", 10, true}, {"b", 11, false}, {"c", 12, true}},
+			want:    10,
+			wantAny: true,
+		},
+		{
+			name:    "gap holds the height while live blocks are processed",
+			saved:   5,
+			resume:  true,
+			blocks:  []cursorBlock{{"live", 20, true}},
+			want:    5,
+			wantAny: true,
+		},
+		{
+			name:    "advanced gap holds the height below the failed height",
+			saved:   5,
+			resume:  true,
+			advance: 9,
+			blocks:  []cursorBlock{{"gap", 6, true}, {"live", 20, true}},
+			want:    8,
+			wantAny: true,
+		},
+		{
+			name:    "closed gap releases the height",
+			saved:   5,
+			resume:  true,
+			closed:  true,
+			blocks:  []cursorBlock{{"gap", 6, true}, {"live", 20, true}},
+			want:    20,
+			wantAny: true,
+		},
+		{
+			name:    "closed gap with a pending replayed block",
+			saved:   5,
+			resume:  true,
+			closed:  true,
+			blocks:  []cursorBlock{{"gap", 6, false}, {"live", 20, true}},
+			want:    5,
+			wantAny: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cursor := NewChainCursor()
+			if test.saved > 0 {
+				cursor.processed[chain] = test.saved
+			}
+
+			if test.resume {
+				heights := cursor.startResume()
+				if heights[chain] != test.saved {
+					t.Fatalf("startResume() = %d, want %d", heights[chain], test.saved)
+				}
+			}
+
+			for _, block := range test.blocks {
+				if !cursor.markSeen(block.hash, chain.From, chain.To, block.height) {
+					t.Fatalf("markSeen(%s) = false for a new block", block.hash)
+				}
+				if block.processed {
+					cursor.markProcessed(chain.From, chain.To, block.height)
+				}
+			}
+
+			if test.advance > 0 {
+				cursor.advanceGap(chain, test.advance)
+			}
+			if test.closed {
+				cursor.closeGap(chain)
+			}
+
+			height, ok := cursor.safeHeight(chain)
+			if ok != test.wantAny || (ok && height != test.want) {
+				t.Errorf("safeHeight() = %d, %t, want %d, %t", height, ok, test.want, test.wantAny)
+			}
+		})
+	}
+}
+
+func TestChainCursorMarkSeenTwice(t *testing.T) {
+	cursor := NewChainCursor()
+	if !cursor.markSeen("a", 0, 0, 1) {
+		t.Fatal("markSeen() = false for a new block")
+	}
+	if cursor.markSeen("a", 0, 0, 1) {
+		t.Error("markSeen() = true for a block seen before")
+	}
+}
+
+func TestChainCursorSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cursor.json")
+
+	cursor := NewChainCursor()
+	cursor.markSeen("a", 1, 2, 7)
+	cursor.markProcessed(1, 2, 7)
+	cursor.markSeen("b", 1, 2, 8)
+	if err := cursor.save(path); err != nil {
+		t.Fatalf("save() error: %s", err)
+	}
+
+	loaded := NewChainCursor()
+	if err := loaded.load(path); err != nil {
+		t.Fatalf("load() error: %s", err)
+	}
+
+	// the pending block is replayed after a restart
+	if height, ok := loaded.safeHeight(ChainIndex{1, 2}); !ok || height != 7 {
+		t.Errorf("safeHeight() after load = %d, %t, want 7, true", height, ok)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+type RichInput struct {
+	Hint           int           `json:"hint"`
+	Key            string        `json:"key"`
+	UnlockScript   string        `json:"unlockScript"`
+	AttoAlphAmount string        `json:"attoAlphAmount"`
+	Address        string        `json:"address"`
+	Tokens         []TokenAmount `json:"tokens"`
+	OutputRefTxId  string        `json:"outputRefTxId"`
+}
+
+type RichTransaction struct {
+	Unsigned struct {
+		TxID   string      `json:"txId"`
+		Inputs []RichInput `json:"inputs"`
+	} `json:"unsigned"`
+	ScriptExecutionOk bool        `json:"scriptExecutionOk"`
+	ContractInputs    []RichInput `json:"contractInputs"`
+}
+
+// txFromBlock builds the transaction from the block payload, inputs only have their output reference
+func txFromBlock(block *Ws, index int) *Transaction {
+	blockTx := block.Params.Transactions[index]
+
+	tx := Transaction{
+		Type:              "Accepted",
+		Hash:              blockTx.Unsigned.TxID,
+		BlockHash:         block.Params.Hash,
+		Timestamp:         block.Params.Timestamp,
+		GasAmount:         blockTx.Unsigned.GasAmount,
+		GasPrice:          blockTx.Unsigned.GasPrice,
+		ScriptExecutionOk: blockTx.ScriptExecutionOk,
+		Coinbase:          len(blockTx.Unsigned.Inputs) == 0,
+	}
+
+	for _, input := range blockTx.Unsigned.Inputs {
+		tx.Inputs = append(tx.Inputs, TxInput{OutputRef: input.OutputRef, UnlockScript: input.UnlockScript})
+	}
+
+	for _, output := range blockTx.Unsigned.FixedOutputs {
+		tx.Outputs = append(tx.Outputs, TxOutput{
+			Type:           "AssetOutput",
+			Hint:           output.Hint,
+			Key:            output.Key,
+			AttoAlphAmount: output.AttoAlphAmount,
+			Address:        output.Address,
+			Tokens:         output.Tokens,
+			Message:        output.Message,
+		})
+	}
+
+	for _, output := range blockTx.GeneratedOutputs {
+		tx.Outputs = append(tx.Outputs, TxOutput{
+			Type:           output.Type,
+			Hint:           output.Hint,
+			Key:            output.Key,
+			AttoAlphAmount: output.AttoAlphAmount,
+			Address:        output.Address,
+			Tokens:         output.Tokens,
+			Message:        output.Message,
+		})
+	}
+
+	return &tx
+}
+
+// resolveInputsFullnode fills address and amount of the inputs using the fullnode rich transaction details.
+// The fullnode needs the tx output ref index enabled (alephium.node.indexes.tx-output-ref-index)
+func resolveInputsFullnode(tx *Transaction) error {
+	richTx, err := fullnodeClient.richTransaction(context.Background(), tx.Hash)
+	if err != nil {
+		return fmt.Errorf("failed to query transaction details: %w", err)
+	}
+
+	if len(richTx.Unsigned.Inputs) == 0 {
+		return fmt.Errorf("no input found for transaction %s", tx.Hash)
+	}
+
+	tx.Inputs = nil
+	for _, input := range append(richTx.Unsigned.Inputs, richTx.ContractInputs...) {
+		tx.Inputs = append(tx.Inputs, TxInput{
+			OutputRef:      OutputRef{Hint: input.Hint, Key: input.Key},
+			UnlockScript:   input.UnlockScript,
+			TxHashRef:      input.OutputRefTxId,
+			Address:        input.Address,
+			AttoAlphAmount: input.AttoAlphAmount,
+			Tokens:         input.Tokens,
+		})
+	}
+
+	return nil
+}
+
+// getTxFullnode returns the transaction decoded from the block with its inputs resolved on the fullnode,
+// false if inputs cannot be resolved
+func getTxFullnode(tx *Transaction) bool {
+	if tx == nil || len(tx.Inputs) == 0 {
+		return false
+	}
+
+	// already resolved
+	if tx.Inputs[0].Address != "" {
+		return true
+	}
+
+	err := resolveInputsFullnode(tx)
+	if err != nil {
+		log.Printf("cannot resolve inputs of %s from fullnode, err: %s\n", tx.Hash, err)
+		return false
+	}
+
+	return true
+}
+
+// hasContractOutput returns true if a contract takes part in the transaction
+func hasContractOutput(tx *Transaction) bool {
+	for _, output := range tx.Outputs {
+		if strings.ToLower(output.Type) == "contractoutput" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resolveContractInputsFullnode adds the contract inputs, which the explorer does not return, from the fullnode
+// rich transaction details. Swaps cannot be decoded without them
+func resolveContractInputsFullnode(tx *Transaction) error {
+	richTx, err := fullnodeClient.richTransaction(context.Background(), tx.Hash)
+	if err != nil {
+		return fmt.Errorf("failed to query transaction details: %w", err)
+	}
+
+	for _, input := range richTx.ContractInputs {
+		tx.Inputs = append(tx.Inputs, TxInput{
+			OutputRef:      OutputRef{Hint: input.Hint, Key: input.Key},
+			TxHashRef:      input.OutputRefTxId,
+			Address:        input.Address,
+			AttoAlphAmount: input.AttoAlphAmount,
+			Tokens:         input.Tokens,
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// SwapData is a swap through a DEX pool, amounts are in human units
+type SwapData struct {
+	pool      string
+	trader    string
+	tokenIn   string
+	symbolIn  string
+	amountIn  float64
+	tokenOut  string
+	symbolOut string
+	amountOut float64
+}
+
+// price returns the effective price of the swap, in ALPH per token when ALPH is one side of the pair
+func (swap SwapData) price() (float64, string) {
+	if swap.amountIn == 0 || swap.amountOut == 0 {
+		return 0, ""
+	}
+
+	if swap.symbolOut == "ALPH" {
+		return swap.amountOut / swap.amountIn, fmt.Sprintf("ALPH per %s", swap.symbolIn)
+	}
+
+	return swap.amountIn / swap.amountOut, fmt.Sprintf("%s per %s", swap.symbolIn, swap.symbolOut)
+}
+
+// direction returns if the trader is buying or selling the token of the pair
+func (swap SwapData) direction() (string, string) {
+	if swap.symbolOut == "ALPH" {
+		return "Sell", swap.symbolIn
+	}
+
+	return "Buy", swap.symbolOut
+}
+
+type swapLeg struct {
+	flow   AssetFlow
+	amount *big.Int
+}
+
+// decodeSwaps recognises pools in the transaction: a contract receiving exactly one asset and sending another one.
+// The contract inputs must be resolved, they are missing from transactions of the explorer
+func decodeSwaps(txData *Transaction, flows []AssetFlow) []SwapData {
+	contracts := make(map[string]bool)
+	for _, output := range txData.Outputs {
+		if strings.ToLower(output.Type) == "contractoutput" {
+			contracts[output.Address] = true
+		}
+	}
+
+	var swaps []SwapData
+	for contract := range contracts {
+		var legsIn, legsOut []swapLeg
+		for _, flow := range flows {
+			for _, recipient := range flow.recipients {
+				if recipient.address == contract {
+					legsIn = append(legsIn, swapLeg{flow, recipient.amount})
+				}
+			}
+			for _, sender := range flow.senders {
+				if sender.address == contract {
+					legsOut = append(legsOut, swapLeg{flow, sender.amount})
+				}
+			}
+		}
+
+		if len(legsIn) != 1 || len(legsOut) != 1 {
+			continue
+		}
+
+		// trader is the biggest sender of the asset given to the pool which is not a contract
+		var trader string
+		for _, sender := range legsIn[0].flow.senders {
+			if !contracts[sender.address] {
+				trader = sender.address
+				break
+			}
+		}
+
+		amountIn, symbolIn := swapLegAmount(legsIn[0])
+		amountOut, symbolOut := swapLegAmount(legsOut[0])
+
+		swaps = append(swaps, SwapData{
+			pool:      contract,
+			trader:    trader,
+			tokenIn:   legsIn[0].flow.tokenId,
+			symbolIn:  symbolIn,
+			amountIn:  amountIn,
+			tokenOut:  legsOut[0].flow.tokenId,
+			symbolOut: symbolOut,
+			amountOut: amountOut,
+		})
+	}
+
+	return swaps
+}
+
+// swapLegAmount returns the amount with decimals applied and the symbol of a swap leg
+func swapLegAmount(leg swapLeg) (float64, string) {
+	amountFloat, _ := new(big.Float).SetInt(leg.amount).Float64()
+
+	if leg.flow.tokenId == alphFlowId {
+		return amountFloat / baseAlph, "ALPH"
+	}
+
+	tokenData := searchTokenData(leg.flow.tokenId)
+	if tokenData.Name == "" {
+		return amountFloat, leg.flow.tokenId[0:6]
+	}
+
+	return amountFloat / math.Pow(10.0, float64(tokenData.Decimals)), tokenData.Symbol
+}
+
+// checkSwaps sends an alert for each swap with a leg above the ALPH or token trigger
+func checkSwaps(txData *Transaction, flows []AssetFlow, txId Tx, chMessages chan Message) {
+	for _, swap := range decodeSwaps(txData, flows) {
+		// keep the token price in ALPH to value tokens
+		if swap.tokenIn == alphFlowId && swap.amountOut > 0 {
+			tokenPrices.set(swap.tokenOut, swap.amountIn/swap.amountOut)
+		} else if swap.tokenOut == alphFlowId && swap.amountIn > 0 {
+			tokenPrices.set(swap.tokenIn, swap.amountOut/swap.amountIn)
+		}
+
+		if swap.trader == "" {
+			continue
+		}
+
+		msg := Message{
+			from:      swap.trader,
+			to:        swap.pool,
+			txId:      txId.id,
+			groupFrom: txId.groupFrom,
+			groupTo:   txId.groupTo,
+			blockHash: txId.blockHash,
+			height:    txId.height,
+			status:    txId.status,
+		}
+
+		triggered := false
+		for _, leg := range []struct {
+			tokenId string
+			amount  float64
+		}{{swap.tokenIn, swap.amountIn}, {swap.tokenOut, swap.amountOut}} {
+			if leg.tokenId == alphFlowId {
+				if leg.amount >= parameters.MinAmountSwapTrigger {
+					msg.amountChain = leg.amount
+					triggered = true
+					break
+				}
+				continue
+			}
+
+			if amountTrigger, found := trackTokens[leg.tokenId]; found && leg.amount >= amountTrigger {
+				tokenData := searchTokenData(leg.tokenId)
+				msg.amountChain = leg.amount * math.Pow(10.0, float64(tokenData.Decimals))
+				msg.tokenData = tokenData
+				triggered = true
+				break
+			}
+		}
+
+		if triggered {
+			swapCopy := swap
+			msg.swap = &swapCopy
+			swapsMetric.Inc()
+			emitMessage(msg, chMessages)
+		}
+	}
+}
+
+// swapFormat formats a swap alert
+func swapFormat(msg Message, isTelegram bool) string {
+	swap := msg.swap
+
+	poolWallet := getAddressName(&swap.pool)
+	poolName := "DEX pool"
+	if poolWallet.Name != "" {
+		poolName = poolWallet.Name
+	}
+
+	traderWallet := getAddressName(&swap.trader)
+	trader, _ := formatAddress(&traderWallet, swap.trader, 0, false)
+
+	side, symbol := swap.direction()
+	price, priceUnit := swap.price()
+
+	var amountFiatString string
+	if swap.symbolIn == "ALPH" || swap.symbolOut == "ALPH" {
+		alphAmount := swap.amountIn
+		if swap.symbolOut == "ALPH" {
+			alphAmount = swap.amountOut
+		}
+		amountFiat := Amount{Value: alphAmount * coinGeckoPrice, Symbol: "USDT"}
+		amountFiatString = "(" + amountFiat.formatHuman() + ")"
+	}
+
+	amountIn := Amount{Value: swap.amountIn, Symbol: "$" + swap.symbolIn}.formatHuman()
+	amountOut := Amount{Value: swap.amountOut, Symbol: "$" + swap.symbolOut}.formatHuman()
+
+	status := statusTag(msg.status)
+
+	var text string
+	if isTelegram {
+		text = fmt.Sprintf("%s🔄 Whale swap on %s (%d -> %d)\n%s %s $%s: %s for %s %s\nPrice: %.6f %s\n\n<a href='%s/#/transactions/%s'>TX link</a>\n", status, poolName, msg.groupFrom, msg.groupTo, trader, strings.ToLower(side), symbol, amountIn, amountOut, amountFiatString, price, priceUnit, parameters.FrontendExplorerUrl, msg.txId)
+	} else {
+		text = fmt.Sprintf("%s🔄 Whale swap on %s\n%s %s $%s: %s for %s %s\nPrice: %.6f %s\n\n%s/#/transactions/%s\n", status, poolName, trader, strings.ToLower(side), symbol, amountIn, amountOut, amountFiatString, price, priceUnit, parameters.FrontendExplorerUrl, msg.txId)
+	}
+
+	return text
+}
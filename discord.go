@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	discordApiUrl = "https://discord.com/api/v10"
+	// webhooks allow 30 messages per minute
+	discordRateLimit = 0.5
+
+	discordColorBuy     = 0x2ecc71
+	discordColorSell    = 0xe74c3c
+	discordColorNeutral = 0x3498db
+)
+
+// DiscordTarget is a webhook, or a channel when the bot token is set, with the alerts it receives
+type DiscordTarget struct {
+	webhookUrl string
+	channelId  string
+	filter     AlertFilter
+}
+
+type DiscordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type DiscordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	Url         string              `json:"url,omitempty"`
+	Color       int                 `json:"color"`
+	Fields      []DiscordEmbedField `json:"fields,omitempty"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+}
+
+type DiscordMessage struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []DiscordEmbed `json:"embeds,omitempty"`
+}
+
+type DiscordNotifier struct {
+	targets  []DiscordTarget
+	botToken string
+	client   *retryablehttp.Client
+}
+
+func init() {
+	registerNotifier("discord", newDiscordNotifier)
+}
+
+func newDiscordNotifier() (Notifier, error) {
+	targets := loadDiscordTargets()
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("DISCORD_WEBHOOKS and DISCORD_CHANNELS are not set")
+	}
+
+	return &DiscordNotifier{targets: targets, botToken: os.Getenv("DISCORD_BOT_TOKEN"), client: newHttpClient()}, nil
+}
+
+// loadDiscordTargets reads the webhooks from DISCORD_WEBHOOKS and the channels from DISCORD_CHANNELS, e.g.
+// "https://discord.com/api/webhooks/1/abc;alph+tokens"
+func loadDiscordTargets() []DiscordTarget {
+	var targets []DiscordTarget
+	for _, target := range loadFilteredTargets("DISCORD_WEBHOOKS") {
+		targets = append(targets, DiscordTarget{webhookUrl: target.value, filter: target.filter})
+	}
+	for _, target := range loadFilteredTargets("DISCORD_CHANNELS") {
+		targets = append(targets, DiscordTarget{channelId: target.value, filter: target.filter})
+	}
+
+	return targets
+}
+
+func (d *DiscordNotifier) Name() string {
+	return "discord"
+}
+
+func (d *DiscordNotifier) Format() Format {
+	return formatText
+}
+
+func (d *DiscordNotifier) MaxLength() int {
+	return 2000
+}
+
+func (d *DiscordNotifier) RateLimit() float64 {
+	return discordRateLimit
+}
+
+// Send posts the text to every target
+func (d *DiscordNotifier) Send(ctx context.Context, text string) error {
+	return d.post(ctx, Notification{text: text}, DiscordMessage{Content: truncateText(text, d.MaxLength())})
+}
+
+// SendNotification posts the alert as an embed to the targets whose filter matches
+func (d *DiscordNotifier) SendNotification(ctx context.Context, notification Notification) error {
+	embed, ok := discordEmbed(notification)
+	if !ok {
+		// the notification is kept so the filters still apply
+		return d.post(ctx, notification, DiscordMessage{Content: truncateText(notification.text, d.MaxLength())})
+	}
+
+	return d.post(ctx, notification, DiscordMessage{Embeds: []DiscordEmbed{embed}})
+}
+
+func (d *DiscordNotifier) post(ctx context.Context, notification Notification, message DiscordMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("cannot marshal discord message: %w", err)
+	}
+
+	var errs []error
+	for _, target := range d.targets {
+		if !target.filter.match(notification) {
+			continue
+		}
+
+		if err := d.postTarget(ctx, target, body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (d *DiscordNotifier) postTarget(ctx context.Context, target DiscordTarget, body []byte) error {
+	url := target.webhookUrl
+	if target.channelId != "" {
+		if d.botToken == "" {
+			return fmt.Errorf("DISCORD_BOT_TOKEN is needed to post to channel %s", target.channelId)
+		}
+		url = fmt.Sprintf("%s/channels/%s/messages", discordApiUrl, target.channelId)
+	}
+
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create discord request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if target.channelId != "" {
+		req.Header.Set("Authorization", "Bot "+d.botToken)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return newHttpError(discordApiUrl, 0, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return newHttpError(discordApiUrl, resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, respBody))
+	}
+
+	return nil
+}
+
+// discordEmbed renders CEX trades, transfers and swaps as embeds, false for the other alerts which are posted
+// as text
+func discordEmbed(notification Notification) (DiscordEmbed, bool) {
+	if notification.cex != nil {
+		return discordCexEmbed(*notification.cex), true
+	}
+
+	msg := notification.msg
+	if msg == nil || msg.followUp || msg.event != nil || msg.bridge != nil {
+		return DiscordEmbed{}, false
+	}
+
+	if msg.swap != nil {
+		return discordSwapEmbed(*msg), true
+	}
+
+	return discordTransferEmbed(*msg), true
+}
+
+func discordCexEmbed(msg MessageCex) DiscordEmbed {
+	side := strings.ToLower(msg.Side)
+	color := discordColorNeutral
+	switch side {
+	case "buy":
+		color = discordColorBuy
+	case "sell":
+		color = discordColorSell
+	}
+
+	return DiscordEmbed{
+		Title: fmt.Sprintf("%s on %s", capitalize(side), msg.ExchangeName),
+		Color: color,
+		Fields: []DiscordEmbedField{
+			{Name: "Volume", Value: msg.AmountLeft.formatHuman(), Inline: true},
+			{Name: "Total", Value: msg.AmountFiat.formatHuman(), Inline: true},
+			{Name: "Price", Value: fmt.Sprintf("%.3f USDT", msg.Price), Inline: true},
+		},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// capitalize upper cases the first letter of an ASCII word
+func capitalize(word string) string {
+	if word == "" {
+		return word
+	}
+
+	return strings.ToUpper(word[:1]) + word[1:]
+}
+
+func discordTransferEmbed(msg Message) DiscordEmbed {
+	_, symbol, amount := msg.asset()
+
+	// deposits to exchanges are sell pressure, withdrawals from exchanges are buys
+	color := discordColorNeutral
+	if getAddressName(&msg.to).ExchangeName != "" {
+		color = discordColorSell
+	} else if getAddressName(&msg.from).ExchangeName != "" {
+		color = discordColorBuy
+	}
+
+	from, _ := formatAddresses(msg.fromAddresses, msg.from, amount, false)
+	to, _ := formatAddresses(msg.toAddresses, msg.to, amount, true)
+
+	fields := []DiscordEmbedField{
+		{Name: "Amount", Value: Amount{Value: amount, Symbol: "$" + symbol}.formatHuman(), Inline: true},
+	}
+	if usd, ok := msg.usdValue(); ok {
+		fields = append(fields, DiscordEmbedField{Name: "USD value", Value: Amount{Value: usd, Symbol: "USDT"}.formatHuman(), Inline: true})
+	}
+	fields = append(fields,
+		DiscordEmbedField{Name: "Groups", Value: fmt.Sprintf("%d -> %d", msg.groupFrom, msg.groupTo), Inline: true},
+		DiscordEmbedField{Name: "From", Value: from},
+		DiscordEmbedField{Name: "To", Value: to},
+	)
+
+	return DiscordEmbed{
+		Title:     strings.ReplaceAll(statusTag(msg.status), "\n", " ") + "Whale transfer",
+		Url:       fmt.Sprintf("%s/#/transactions/%s", parameters.FrontendExplorerUrl, msg.txId),
+		Color:     color,
+		Fields:    fields,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+func discordSwapEmbed(msg Message) DiscordEmbed {
+	swap := msg.swap
+	side, symbol := swap.direction()
+	price, priceUnit := swap.price()
+
+	color := discordColorBuy
+	if side == "Sell" {
+		color = discordColorSell
+	}
+
+	poolName := "DEX pool"
+	if poolWallet := getAddressName(&swap.pool); poolWallet.Name != "" {
+		poolName = poolWallet.Name
+	}
+	traderWallet := getAddressName(&swap.trader)
+	trader, _ := formatAddress(&traderWallet, swap.trader, 0, false)
+
+	fields := []DiscordEmbedField{
+		{Name: "Sold", Value: Amount{Value: swap.amountIn, Symbol: "$" + swap.symbolIn}.formatHuman(), Inline: true},
+		{Name: "Bought", Value: Amount{Value: swap.amountOut, Symbol: "$" + swap.symbolOut}.formatHuman(), Inline: true},
+		{Name: "Price", Value: fmt.Sprintf("%.6f %s", price, priceUnit), Inline: true},
+	}
+	if usd, ok := msg.usdValue(); ok {
+		fields = append(fields, DiscordEmbedField{Name: "USD value", Value: Amount{Value: usd, Symbol: "USDT"}.formatHuman(), Inline: true})
+	}
+	fields = append(fields,
+		DiscordEmbedField{Name: "Trader", Value: trader, Inline: true},
+		DiscordEmbedField{Name: "Groups", Value: fmt.Sprintf("%d -> %d", msg.groupFrom, msg.groupTo), Inline: true},
+	)
+
+	return DiscordEmbed{
+		Title:     fmt.Sprintf("%sWhale %s $%s on %s", strings.ReplaceAll(statusTag(msg.status), "\n", " "), strings.ToLower(side), symbol, poolName),
+		Url:       fmt.Sprintf("%s/#/transactions/%s", parameters.FrontendExplorerUrl, msg.txId),
+		Color:     color,
+		Fields:    fields,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+}
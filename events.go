@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	alephium "github.com/alephium/go-sdk"
+)
+
+// max number of events fetched at once for a contract
+const contractEventsLimit = 100
+
+// EventRule matches an event of a contract, amountField is the index of the amount field and addressField the
+// index of the address field. Both default to -1 when omitted: every event matches and no address is shown
+type EventRule struct {
+	EventIndex   int     `json:"eventIndex"`
+	Name         string  `json:"name"`
+	AmountField  int     `json:"amountField"`
+	AddressField int     `json:"addressField"`
+	TokenId      string  `json:"tokenId"`
+	MinAmount    float64 `json:"minAmount"`
+}
+
+func (rule *EventRule) UnmarshalJSON(data []byte) error {
+	// alias without the method to avoid the recursion
+	type eventRule EventRule
+	decoded := eventRule{AmountField: -1, AddressField: -1}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*rule = EventRule(decoded)
+	return nil
+}
+
+type WatchedContract struct {
+	Address string      `json:"address"`
+	Name    string      `json:"name"`
+	Rules   []EventRule `json:"rules"`
+}
+
+// EventData is a decoded contract event matching a rule
+type EventData struct {
+	contract string
+	name     string
+	rule     string
+	address  string
+	amount   float64
+	tokenId  string
+	symbol   string
+}
+
+// EventCounters keeps the next event to fetch for each contract
+type EventCounters struct {
+	mu       sync.Mutex
+	counters map[string]int
+}
+
+var eventCounters = &EventCounters{counters: make(map[string]int)}
+
+func (e *EventCounters) load(path string) error {
+	dataBytes, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read events counter file: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return json.Unmarshal(dataBytes, &e.counters)
+}
+
+func (e *EventCounters) save(path string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return writeJsonFile(path, e.counters)
+}
+
+func (e *EventCounters) get(address string) (int, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	counter, ok := e.counters[address]
+	return counter, ok
+}
+
+func (e *EventCounters) set(address string, counter int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.counters[address] = counter
+}
+
+func loadWatchedContracts(path string) ([]WatchedContract, error) {
+	dataBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read contracts file: %w", err)
+	}
+
+	var contracts []WatchedContract
+	err = json.Unmarshal(dataBytes, &contracts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse contracts file: %w", err)
+	}
+
+	return contracts, nil
+}
+
+// getContractEventsLoop polls the events of the watched contracts and sends an alert for each event matching a rule
+func getContractEventsLoop(ctx context.Context, chMessages chan Message) {
+	contracts, err := loadWatchedContracts(parameters.ContractsFile)
+	if err != nil {
+		log.Printf("cannot load watched contracts, err: %s\n", err)
+		return
+	}
+
+	err = eventCounters.load(parameters.EventsCounterFile)
+	if err != nil {
+		log.Printf("cannot load events counter, err: %s\n", err)
+	}
+
+	for {
+		for _, contract := range contracts {
+			checkContractEvents(contract, chMessages)
+		}
+
+		if err := eventCounters.save(parameters.EventsCounterFile); err != nil {
+			log.Printf("Error saving events counter, err: %s\n", err)
+		}
+
+		if !sleepCtx(ctx, time.Duration(parameters.EventsPollingIntervalSec)*time.Second) {
+			return
+		}
+	}
+}
+
+func checkContractEvents(contract WatchedContract, chMessages chan Message) {
+	start, ok := eventCounters.get(contract.Address)
+	if !ok {
+		// first time the contract is watched, only new events are checked
+		count, err := fullnodeClient.contractEventsCount(context.Background(), contract.Address)
+		if err != nil {
+			log.Printf("Cannot get events count of %s, err: %s\n", contract.Address, err)
+			return
+		}
+		eventCounters.set(contract.Address, count)
+		return
+	}
+
+	for {
+		eventsResp, err := fullnodeClient.contractEvents(context.Background(), contract.Address, start, contractEventsLimit)
+		if err != nil {
+			log.Printf("Cannot get events of %s, err: %s\n", contract.Address, err)
+			return
+		}
+
+		for _, event := range eventsResp.Events {
+			contractEventsMetric.Inc()
+			for _, rule := range contract.Rules {
+				if eventData, matched := matchEventRule(contract, rule, event); matched {
+					pushMessage(Message{txId: event.TxId, event: &eventData}, chMessages)
+				}
+			}
+		}
+
+		if int(eventsResp.NextStart) <= start {
+			return
+		}
+		start = int(eventsResp.NextStart)
+		eventCounters.set(contract.Address, start)
+
+		if len(eventsResp.Events) < contractEventsLimit {
+			return
+		}
+	}
+}
+
+// matchEventRule decodes the event and returns true if it matches the rule
+func matchEventRule(contract WatchedContract, rule EventRule, event alephium.ContractEvent) (EventData, bool) {
+	eventData := EventData{contract: contract.Address, name: contract.Name, rule: rule.Name}
+
+	if int(event.EventIndex) != rule.EventIndex {
+		return eventData, false
+	}
+
+	if rule.AddressField >= 0 && rule.AddressField < len(event.Fields) {
+		if address, ok := eventFieldString(event.Fields[rule.AddressField]); ok {
+			eventData.address = address
+		}
+	}
+
+	if rule.AmountField < 0 {
+		return eventData, true
+	}
+
+	if rule.AmountField >= len(event.Fields) {
+		log.Printf("event %d of %s has no field %d\n", event.EventIndex, contract.Address, rule.AmountField)
+		return eventData, false
+	}
+
+	value, ok := eventFieldString(event.Fields[rule.AmountField])
+	if !ok {
+		return eventData, false
+	}
+
+	amountInt, ok := new(big.Float).SetString(value)
+	if !ok {
+		log.Printf("cannot parse event amount %s\n", value)
+		return eventData, false
+	}
+	amount, _ := amountInt.Float64()
+
+	eventData.symbol = "ALPH"
+	eventData.tokenId = rule.TokenId
+	decimals := 18
+	if rule.TokenId != "" {
+		tokenData := searchTokenData(rule.TokenId)
+		eventData.symbol = tokenData.Symbol
+		decimals = tokenData.Decimals
+	}
+	eventData.amount = amount / math.Pow(10.0, float64(decimals))
+
+	return eventData, eventData.amount >= rule.MinAmount
+}
+
+// eventFieldString returns the value of an address or number field
+func eventFieldString(field alephium.Val) (string, bool) {
+	switch {
+	case field.ValAddress != nil:
+		return field.ValAddress.Value, true
+	case field.ValU256 != nil:
+		return field.ValU256.Value, true
+	case field.ValI256 != nil:
+		return field.ValI256.Value, true
+	}
+
+	return "", false
+}
+
+// eventFormat formats a contract event alert
+func eventFormat(msg Message, isTelegram bool) string {
+	event := msg.event
+
+	name := event.name
+	if name == "" {
+		name = event.contract
+	}
+
+	text := fmt.Sprintf("📣 %s on %s", event.rule, name)
+	if event.symbol != "" {
+		text += fmt.Sprintf("\nAmount: %s", Amount{Value: event.amount, Symbol: "$" + event.symbol}.formatHuman())
+	}
+
+	if event.address != "" {
+		namedWallet := getAddressName(&event.address)
+		address, _ := formatAddress(&namedWallet, event.address, event.amount, false)
+		text += fmt.Sprintf("\nAddress: %s", address)
+	}
+
+	if isTelegram {
+		text += fmt.Sprintf("\n\n<a href='%s/#/transactions/%s'>TX link</a>\n", parameters.FrontendExplorerUrl, msg.txId)
+	} else {
+		text += fmt.Sprintf("\n\n%s/#/transactions/%s\n", parameters.FrontendExplorerUrl, msg.txId)
+	}
+
+	return text
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	// consecutive failures opening the circuit
+	explorerBreakerThreshold = 5
+	// time the circuit stays open before a trial request
+	explorerBreakerCooldown = 30 * time.Second
+)
+
+var errExplorerUnavailable = errors.New("explorer circuit open")
+
+// RateLimiter is a token bucket refilled at rate tokens per second
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait blocks until a token is available
+func (r *RateLimiter) wait() {
+	for {
+		r.mu.Lock()
+		now := time.Now()
+		r.tokens = min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.rate)
+		r.last = now
+
+		if r.tokens >= 1 {
+			r.tokens--
+			r.mu.Unlock()
+			return
+		}
+
+		delay := time.Duration((1 - r.tokens) / r.rate * float64(time.Second))
+		r.mu.Unlock()
+		time.Sleep(delay)
+	}
+}
+
+type lruEntry struct {
+	key   string
+	value []byte
+}
+
+// LruCache keeps the most recently used responses
+type LruCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+func NewLruCache(size int) *LruCache {
+	return &LruCache{size: size, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (c *LruCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+
+	return element.Value.(*lruEntry).value, true
+}
+
+func (c *LruCache) add(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size <= 0 {
+		return
+	}
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*lruEntry).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// CircuitBreaker stops calling the explorer after consecutive failures, a trial request is let through once
+// the cooldown is over
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < explorerBreakerThreshold {
+		return true
+	}
+
+	// half open, only one request at a time checks if the explorer is back
+	if time.Now().After(b.openUntil) && !b.trial {
+		b.trial = true
+		return true
+	}
+
+	return false
+}
+
+func (b *CircuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trial = false
+	explorerBreakerOpenMetric.Set(0)
+}
+
+func (b *CircuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+	if b.failures >= explorerBreakerThreshold {
+		if b.failures == explorerBreakerThreshold {
+			log.Printf("Explorer failing, pausing requests for %s\n", explorerBreakerCooldown)
+		}
+		b.openUntil = time.Now().Add(explorerBreakerCooldown)
+		explorerBreakerOpenMetric.Set(1)
+	}
+}
+
+// ExplorerClient queries the explorer backend with a shared connection pool, rate limiting, caching and a
+// circuit breaker
+type ExplorerClient struct {
+	baseUrl string
+	client  *retryablehttp.Client
+	limiter *RateLimiter
+	cache   *LruCache
+	breaker *CircuitBreaker
+}
+
+var explorerClient *ExplorerClient
+
+func NewExplorerClient(baseUrl string, ratePerSec float64, cacheSize int) *ExplorerClient {
+	client := retryablehttp.NewClient()
+	client.RetryMax = 2
+	client.Logger = nil
+	client.HTTPClient.Timeout = 30 * time.Second
+
+	return &ExplorerClient{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		client:  client,
+		limiter: NewRateLimiter(ratePerSec, max(1, int(ratePerSec))),
+		cache:   NewLruCache(cacheSize),
+		breaker: &CircuitBreaker{},
+	}
+}
+
+// get queries the path, endpoint is the metric label. A 404 is not a failure of the explorer
+func (e *ExplorerClient) get(endpoint string, path string) ([]byte, int, error) {
+	if !e.breaker.allow() {
+		explorerRequestsMetric.WithLabelValues(endpoint, "circuit_open").Inc()
+		return []byte{}, 0, errExplorerUnavailable
+	}
+
+	e.limiter.wait()
+
+	url := e.baseUrl + path
+	start := time.Now()
+	resp, err := e.client.Get(url)
+	explorerLatencyMetric.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	if err != nil {
+		e.breaker.failure()
+		explorerRequestsMetric.WithLabelValues(endpoint, "error").Inc()
+		return []byte{}, 0, newHttpError(url, 0, err)
+	}
+	defer resp.Body.Close()
+
+	explorerRequestsMetric.WithLabelValues(endpoint, fmt.Sprintf("%d", resp.StatusCode)).Inc()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		e.breaker.failure()
+		return []byte{}, resp.StatusCode, newHttpError(url, 0, err)
+	}
+
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		e.breaker.failure()
+	} else {
+		e.breaker.success()
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return []byte{}, resp.StatusCode, newHttpError(url, resp.StatusCode, fmt.Errorf("%s", resp.Status))
+	}
+
+	return bodyBytes, resp.StatusCode, nil
+}
+
+// getTransaction returns the transaction, found is false while the explorer does not know it yet. Accepted
+// transactions do not change anymore and are cached
+func (e *ExplorerClient) getTransaction(txId string) (Transaction, bool, error) {
+	var tx Transaction
+
+	if dataBytes, ok := e.cache.get(txId); ok {
+		explorerCacheHitsMetric.Inc()
+		err := json.Unmarshal(dataBytes, &tx)
+		return tx, err == nil, err
+	}
+
+	dataBytes, statusCode, err := e.get("transactions", fmt.Sprintf("/transactions/%s", txId))
+	if statusCode == http.StatusNotFound {
+		return tx, false, nil
+	}
+	if err != nil {
+		return tx, false, err
+	}
+
+	err = decodeJson(e.baseUrl, dataBytes, &tx)
+	if err != nil {
+		return tx, false, err
+	}
+
+	if strings.ToLower(tx.Type) == "accepted" {
+		e.cache.add(txId, dataBytes)
+	}
+
+	return tx, true, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+)
+
+// AlertFilter selects the kinds of alerts sent to a destination
+type AlertFilter struct {
+	alph   bool
+	tokens bool
+	cex    bool
+}
+
+var allAlerts = AlertFilter{alph: true, tokens: true, cex: true}
+
+// parseAlertFilter parses kinds joined with "+", e.g. "alph+tokens", "cex" or "all". Empty means all
+func parseAlertFilter(value string) (AlertFilter, error) {
+	if value == "" {
+		return allAlerts, nil
+	}
+
+	var filter AlertFilter
+	for _, kind := range strings.Split(strings.ToLower(value), "+") {
+		switch strings.TrimSpace(kind) {
+		case "all":
+			filter = allAlerts
+		case "alph":
+			filter.alph = true
+		case "tokens":
+			filter.tokens = true
+		case "cex":
+			filter.cex = true
+		default:
+			return filter, fmt.Errorf("unknown alert kind %s", kind)
+		}
+	}
+
+	return filter, nil
+}
+
+// FilteredTarget is a destination of a sink, a webhook url or a channel id, with the alerts it receives
+type FilteredTarget struct {
+	value  string
+	filter AlertFilter
+}
+
+// loadFilteredTargets parses "target;filter,target;filter" from the env var, the filter is optional
+func loadFilteredTargets(env string) []FilteredTarget {
+	var targets []FilteredTarget
+	for _, item := range splitList(os.Getenv(env)) {
+		parts := strings.Split(item, ";")
+		if len(parts) > 2 {
+			log.Printf("cannot parse %s target %s\n", env, item)
+			continue
+		}
+
+		var filterValue string
+		if len(parts) == 2 {
+			filterValue = parts[1]
+		}
+		filter, err := parseAlertFilter(filterValue)
+		if err != nil {
+			log.Printf("cannot parse filter of %s target %s, err: %s\n", env, parts[0], err)
+			continue
+		}
+
+		targets = append(targets, FilteredTarget{value: parts[0], filter: filter})
+	}
+
+	return targets
+}
+
+// match returns true if the alert is of a selected kind. Contract events without amount match when ALPH or
+// tokens are selected, alerts restored with their text only cannot be checked and only match all alerts
+func (f AlertFilter) match(notification Notification) bool {
+	if notification.cex != nil {
+		return f.cex
+	}
+
+	if notification.msg == nil {
+		return f == allAlerts
+	}
+
+	tokenId, symbol, _ := notification.msg.asset()
+	switch {
+	case symbol == "":
+		return f.alph || f.tokens
+	case tokenId == alphFlowId:
+		return f.alph
+	default:
+		return f.tokens
+	}
+}
+
+// asset returns the token id (alphFlowId for ALPH), the symbol and the amount with decimals applied of the
+// asset moved by the alert, the symbol is empty for contract events without amount
+func (msg Message) asset() (string, string, float64) {
+	switch {
+	case msg.bridge != nil:
+		return msg.bridge.tokenId, msg.bridge.symbol, msg.bridge.amount
+	case msg.event != nil:
+		return msg.event.tokenId, msg.event.symbol, msg.event.amount
+	}
+
+	amount, symbol := msg.humanAmount()
+	if msg.tokenData.Name == "" {
+		return alphFlowId, symbol, amount
+	}
+
+	return msg.tokenData.ID, symbol, amount
+}
+
+// usdValue returns the USD value of the alert, false if the price of the asset is unknown
+func (msg Message) usdValue() (float64, bool) {
+	if msg.bridge != nil {
+		return msg.bridge.usd, msg.bridge.usd > 0
+	}
+
+	tokenId, symbol, amount := msg.asset()
+	if symbol == "" {
+		return 0, false
+	}
+
+	price, ok := tokenUsdPrice(tokenId, symbol)
+	if !ok {
+		return 0, false
+	}
+
+	return math.Abs(amount) * price, true
+}
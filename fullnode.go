@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	alephium "github.com/alephium/go-sdk"
+)
+
+// number of groups on Alephium mainnet, there is groupNum*groupNum chains
+const groupNum = 4
+
+// default timeout of a fullnode call, failover to other nodes included
+const fullnodeTimeout = 30 * time.Second
+
+// FullnodeClient queries the fullnode pool and decodes the responses into the go-sdk models. Blocks keep the
+// websocket format so both sources go through the same pipeline
+type FullnodeClient struct {
+	pool    *FullnodePool
+	timeout time.Duration
+}
+
+var fullnodeClient *FullnodeClient
+
+func NewFullnodeClient(pool *FullnodePool, timeout time.Duration) *FullnodeClient {
+	return &FullnodeClient{pool: pool, timeout: timeout}
+}
+
+// get queries the path and decodes the response into value, the client timeout applies if ctx has no deadline
+func (c *FullnodeClient) get(ctx context.Context, path string, value any) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	dataBytes, node, err := c.pool.get(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	return decodeJson(fmt.Sprintf("https://%s%s", node, path), dataBytes, value)
+}
+
+func (c *FullnodeClient) chainInfo(ctx context.Context, groupFrom int, groupTo int) (alephium.ChainInfo, error) {
+	var chainInfo alephium.ChainInfo
+	err := c.get(ctx, fmt.Sprintf("/blockflow/chain-info?fromGroup=%d&toGroup=%d", groupFrom, groupTo), &chainInfo)
+
+	return chainInfo, err
+}
+
+func (c *FullnodeClient) hashesAtHeight(ctx context.Context, groupFrom int, groupTo int, height int) (alephium.HashesAtHeight, error) {
+	var hashes alephium.HashesAtHeight
+	err := c.get(ctx, fmt.Sprintf("/blockflow/hashes?fromGroup=%d&toGroup=%d&height=%d", groupFrom, groupTo, height), &hashes)
+
+	return hashes, err
+}
+
+// block returns the block wrapped as if it was received from the websocket, the block entry of the REST API
+// has the same format as the block_notify params
+func (c *FullnodeClient) block(ctx context.Context, blockHash string) (*Ws, error) {
+	block := Ws{Method: block_notify}
+	err := c.get(ctx, fmt.Sprintf("/blockflow/blocks/%s", blockHash), &block.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &block, nil
+}
+
+// blocksByTime returns the blocks of every chain mined between two timestamps in milliseconds
+func (c *FullnodeClient) blocksByTime(ctx context.Context, fromTs int64, toTs int64) ([]*Ws, error) {
+	var blocksResp struct {
+		Blocks [][]json.RawMessage `json:"blocks"`
+	}
+	err := c.get(ctx, fmt.Sprintf("/blockflow/blocks?fromTs=%d&toTs=%d", fromTs, toTs), &blocksResp)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []*Ws
+	for _, chainBlocks := range blocksResp.Blocks {
+		for _, rawBlock := range chainBlocks {
+			block := Ws{Method: block_notify}
+			err = json.Unmarshal(rawBlock, &block.Params)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse block: %w", err)
+			}
+			blocks = append(blocks, &block)
+		}
+	}
+
+	return blocks, nil
+}
+
+func (c *FullnodeClient) isBlockInMainChain(ctx context.Context, blockHash string) (bool, error) {
+	var isMainChain bool
+	err := c.get(ctx, fmt.Sprintf("/blockflow/is-block-in-main-chain?blockHash=%s", blockHash), &isMainChain)
+
+	return isMainChain, err
+}
+
+// richTransaction returns the transaction with its resolved inputs, the endpoint is newer than the go-sdk
+func (c *FullnodeClient) richTransaction(ctx context.Context, txId string) (RichTransaction, error) {
+	var richTx RichTransaction
+	err := c.get(ctx, fmt.Sprintf("/transactions/rich-details/%s", txId), &richTx)
+
+	return richTx, err
+}
+
+func (c *FullnodeClient) balance(ctx context.Context, address string) (alephium.Balance, error) {
+	var balance alephium.Balance
+	err := c.get(ctx, fmt.Sprintf("/addresses/%s/balance", address), &balance)
+
+	return balance, err
+}
+
+func (c *FullnodeClient) mempoolTransactions(ctx context.Context) ([]alephium.MempoolTransactions, error) {
+	var mempool []alephium.MempoolTransactions
+	err := c.get(ctx, "/mempool/transactions", &mempool)
+
+	return mempool, err
+}
+
+func (c *FullnodeClient) contractEvents(ctx context.Context, address string, start int, limit int) (alephium.ContractEvents, error) {
+	var events alephium.ContractEvents
+	err := c.get(ctx, fmt.Sprintf("/events/contract/%s?start=%d&limit=%d", address, start, limit), &events)
+
+	return events, err
+}
+
+func (c *FullnodeClient) txEvents(ctx context.Context, txId string) (alephium.ContractEventsByTxId, error) {
+	var events alephium.ContractEventsByTxId
+	err := c.get(ctx, fmt.Sprintf("/events/tx-id/%s", txId), &events)
+
+	return events, err
+}
+
+func (c *FullnodeClient) contractEventsCount(ctx context.Context, address string) (int, error) {
+	var count int
+	err := c.get(ctx, fmt.Sprintf("/events/contract/current-count/%s", address), &count)
+
+	return count, err
+}
+
+// get the current height of a chain from the fullnode
+func getChainHeight(groupFrom int, groupTo int) (int, error) {
+	chainInfo, err := fullnodeClient.chainInfo(context.Background(), groupFrom, groupTo)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query chain info: %w", err)
+	}
+
+	return int(chainInfo.CurrentHeight), nil
+}
+
+// get all block hashes at a given height, uncles included
+func getBlockHashesAtHeight(groupFrom int, groupTo int, height int) ([]string, error) {
+	hashes, err := fullnodeClient.hashesAtHeight(context.Background(), groupFrom, groupTo, height)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query block hashes: %w", err)
+	}
+
+	return hashes.Headers, nil
+}
+
+// get a block from the fullnode, wrapped as if it was received from the websocket
+func getBlockFullnode(blockHash string) (*Ws, error) {
+	block, err := fullnodeClient.block(context.Background(), blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query block: %w", err)
+	}
+
+	return block, nil
+}
+
+// get all blocks of a chain at a given height, uncles included
+func getBlocksAtHeight(groupFrom int, groupTo int, height int) ([]*Ws, error) {
+	hashes, err := getBlockHashesAtHeight(groupFrom, groupTo, height)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []*Ws
+	for _, hash := range hashes {
+		block, err := getBlockFullnode(hash)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+
+	return blocks, nil
+}
+
+// get blocks of every chain mined between two timestamps in milliseconds
+func getBlocksByTime(fromTs int64, toTs int64) ([]*Ws, error) {
+	blocks, err := fullnodeClient.blocksByTime(context.Background(), fromTs, toTs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocks: %w", err)
+	}
+
+	return blocks, nil
+}
+
+// tokenAmountsFromSdk converts the tokens of the go-sdk models
+func tokenAmountsFromSdk(tokens []alephium.Token) []TokenAmount {
+	var tokenAmounts []TokenAmount
+	for _, token := range tokens {
+		tokenAmounts = append(tokenAmounts, TokenAmount{ID: token.Id, Amount: token.Amount})
+	}
+
+	return tokenAmounts
+}
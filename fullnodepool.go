@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	alephium "github.com/alephium/go-sdk"
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	fullnodeProbeInterval = 30 * time.Second
+	// max number of blocks a node can be behind the highest one to stay healthy
+	fullnodeMaxLag = 3
+	// error rate above which a node is considered unhealthy
+	fullnodeMaxErrorRate = 0.5
+
+	// the websocket node is kept while it is healthy, unless the best node is ahead of it by that many blocks,
+	// has an error rate lower by that margin or a latency that many times lower
+	wsSwitchLag             = 2
+	wsSwitchErrorRateMargin = 0.2
+	wsSwitchLatencyRatio    = 3
+)
+
+type FullnodeNode struct {
+	api       string
+	ws        string
+	healthy   bool
+	height    int
+	latency   time.Duration
+	errorRate float64
+}
+
+// FullnodePool keeps the health of every fullnode and picks the healthiest for each call
+type FullnodePool struct {
+	mu     sync.Mutex
+	nodes  []*FullnodeNode
+	client *retryablehttp.Client
+}
+
+var fullnodePool *FullnodePool
+
+// NewFullnodePool creates the pool from comma separated lists of hosts, websocket hosts are paired with the api
+// hosts by position, the last one is reused if there is less websocket hosts
+func NewFullnodePool(apiHosts string, wsHosts string) *FullnodePool {
+	client := retryablehttp.NewClient()
+	client.RetryMax = 1
+	client.Logger = nil
+	client.HTTPClient.Timeout = 30 * time.Second
+
+	pool := &FullnodePool{client: client}
+
+	wsList := splitList(wsHosts)
+	for i, api := range splitList(apiHosts) {
+		node := &FullnodeNode{api: api, healthy: true}
+		if len(wsList) > 0 {
+			node.ws = wsList[min(i, len(wsList)-1)]
+		}
+		pool.nodes = append(pool.nodes, node)
+	}
+
+	return pool
+}
+
+func splitList(hosts string) []string {
+	var list []string
+	for _, host := range strings.Split(hosts, ",") {
+		host = strings.TrimSpace(host)
+		if host != "" {
+			list = append(list, host)
+		}
+	}
+
+	return list
+}
+
+// ordered returns the nodes, healthiest first
+func (p *FullnodePool) ordered() []*FullnodeNode {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	maxHeight := 0
+	for _, node := range p.nodes {
+		if node.height > maxHeight {
+			maxHeight = node.height
+		}
+	}
+
+	nodes := make([]*FullnodeNode, len(p.nodes))
+	copy(nodes, p.nodes)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].healthy != nodes[j].healthy {
+			return nodes[i].healthy
+		}
+		lagI, lagJ := maxHeight-nodes[i].height, maxHeight-nodes[j].height
+		if lagI != lagJ {
+			return lagI < lagJ
+		}
+		if nodes[i].errorRate != nodes[j].errorRate {
+			return nodes[i].errorRate < nodes[j].errorRate
+		}
+		return nodes[i].latency < nodes[j].latency
+	})
+
+	return nodes
+}
+
+// wsHost returns the websocket host of the healthiest node
+func (p *FullnodePool) wsHost() string {
+	for _, node := range p.ordered() {
+		if node.ws != "" {
+			return node.ws
+		}
+	}
+
+	return ""
+}
+
+// isBestWs returns false if the node serving this websocket host should be left for a clearly healthier one, small
+// differences between the nodes do not trigger a reconnection
+func (p *FullnodePool) isBestWs(wsHost string) bool {
+	var best *FullnodeNode
+	for _, node := range p.ordered() {
+		if node.ws != "" {
+			best = node
+			break
+		}
+	}
+	if best == nil || best.ws == wsHost {
+		return true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var current *FullnodeNode
+	for _, node := range p.nodes {
+		if node.ws == wsHost {
+			current = node
+			break
+		}
+	}
+
+	switch {
+	case current == nil:
+		return false
+	case !current.healthy:
+		return !best.healthy
+	case best.height-current.height >= wsSwitchLag:
+		return false
+	case current.errorRate-best.errorRate >= wsSwitchErrorRateMargin:
+		return false
+	case best.latency > 0 && current.latency > wsSwitchLatencyRatio*best.latency:
+		return false
+	}
+
+	return true
+}
+
+func (p *FullnodePool) record(node *FullnodeNode, latency time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	failed := 0.0
+	if err != nil {
+		failed = 1.0
+		fullnodeErrorsMetric.WithLabelValues(node.api).Inc()
+	}
+	node.errorRate = 0.9*node.errorRate + 0.1*failed
+	fullnodeRequestsMetric.WithLabelValues(node.api).Inc()
+
+	if err == nil {
+		node.latency = latency
+		fullnodeLatencyMetric.WithLabelValues(node.api).Set(latency.Seconds())
+	}
+}
+
+func (p *FullnodePool) doGet(ctx context.Context, node *FullnodeNode, path string) ([]byte, int, error) {
+	url := fmt.Sprintf("https://%s%s", node.api, path)
+
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return []byte{}, 0, fmt.Errorf("cannot create request, url: %s, err: %s", url, err)
+	}
+
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	if err != nil {
+		httpErr := newHttpError(url, 0, err)
+		p.record(node, 0, httpErr)
+		return []byte{}, 0, httpErr
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		err = newHttpError(url, 0, err)
+	} else if resp.StatusCode >= 500 {
+		err = newHttpError(url, resp.StatusCode, fmt.Errorf("%s", resp.Status))
+	}
+	p.record(node, time.Since(start), err)
+	if err != nil {
+		return []byte{}, resp.StatusCode, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return []byte{}, resp.StatusCode, newHttpError(url, resp.StatusCode, fmt.Errorf("%s", resp.Status))
+	}
+
+	return bodyBytes, resp.StatusCode, nil
+}
+
+// get queries the path on the healthiest node and fails over to the next ones on transport or server errors,
+// returns the host of the node which answered
+func (p *FullnodePool) get(ctx context.Context, path string) ([]byte, string, error) {
+	var lastErr error
+	var lastNode string
+	for _, node := range p.ordered() {
+		dataBytes, statusCode, err := p.doGet(ctx, node, path)
+		if err == nil {
+			return dataBytes, node.api, nil
+		}
+
+		// the node answered or the caller gave up, the error is not related to the node health
+		if (statusCode > 0 && statusCode < 500) || ctx.Err() != nil {
+			return dataBytes, node.api, err
+		}
+
+		lastErr, lastNode = err, node.api
+		fullnodeFailoversMetric.Inc()
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no fullnode configured")
+	}
+
+	return []byte{}, lastNode, lastErr
+}
+
+// probe checks the height, latency and errors of every node
+func (p *FullnodePool) probe() {
+	probeOk := make(map[*FullnodeNode]bool)
+	for _, node := range p.nodes {
+		ctx, cancel := context.WithTimeout(context.Background(), fullnodeTimeout)
+		dataBytes, _, err := p.doGet(ctx, node, "/blockflow/chain-info?fromGroup=0&toGroup=0")
+		cancel()
+
+		var chainInfo alephium.ChainInfo
+		if err == nil {
+			err = json.Unmarshal(dataBytes, &chainInfo)
+		}
+
+		if err != nil {
+			log.Printf("Fullnode %s probe failed, err: %s\n", node.api, err)
+			continue
+		}
+
+		p.mu.Lock()
+		node.height = int(chainInfo.CurrentHeight)
+		p.mu.Unlock()
+		probeOk[node] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	maxHeight := 0
+	for _, node := range p.nodes {
+		if node.height > maxHeight {
+			maxHeight = node.height
+		}
+	}
+
+	for _, node := range p.nodes {
+		lag := maxHeight - node.height
+		node.healthy = probeOk[node] && lag <= fullnodeMaxLag && node.errorRate < fullnodeMaxErrorRate
+
+		healthy := 0.0
+		if node.healthy {
+			healthy = 1.0
+		}
+		fullnodeHealthyMetric.WithLabelValues(node.api).Set(healthy)
+		fullnodeLagMetric.WithLabelValues(node.api).Set(float64(lag))
+	}
+}
+
+func (p *FullnodePool) probeLoop(ctx context.Context) {
+	for {
+		p.probe()
+		if !sleepCtx(ctx, fullnodeProbeInterval) {
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFullnodePoolIsBestWs(t *testing.T) {
+	tests := []struct {
+		name    string
+		current FullnodeNode
+		other   FullnodeNode
+		keep    bool
+	}{
+		{
+			name:    "current is the best",
+			current: FullnodeNode{healthy: true, height: 100, latency: 50 * time.Millisecond},
+			other:   FullnodeNode{healthy: true, height: 100, latency: 80 * time.Millisecond},
+			keep:    true,
+		},
+		{
+			name:    "slightly slower",
+			current: FullnodeNode{healthy: true, height: 100, latency: 80 * time.Millisecond},
+			other:   FullnodeNode{healthy: true, height: 100, latency: 50 * time.Millisecond},
+			keep:    true,
+		},
+		{
+			name:    "one block behind",
+			current: FullnodeNode{healthy: true, height: 99},
+			other:   FullnodeNode{healthy: true, height: 100},
+			keep:    true,
+		},
+		{
+			name:    "slightly more errors",
+			current: FullnodeNode{healthy: true, height: 100, errorRate: 0.1},
+			other:   FullnodeNode{healthy: true, height: 100},
+			keep:    true,
+		},
+		{
+			name:    "unhealthy",
+			current: FullnodeNode{healthy: false, height: 100},
+			other:   FullnodeNode{healthy: true, height: 100},
+			keep:    false,
+		},
+		{
+			name:    "every node unhealthy",
+			current: FullnodeNode{healthy: false, height: 90},
+			other:   FullnodeNode{healthy: false, height: 100},
+			keep:    true,
+		},
+		{
+			name:    "lagging",
+			current: FullnodeNode{healthy: true, height: 98},
+			other:   FullnodeNode{healthy: true, height: 100},
+			keep:    false,
+		},
+		{
+			name:    "many more errors",
+			current: FullnodeNode{healthy: true, height: 100, errorRate: 0.3},
+			other:   FullnodeNode{healthy: true, height: 100},
+			keep:    false,
+		},
+		{
+			name:    "much slower",
+			current: FullnodeNode{healthy: true, height: 100, latency: 400 * time.Millisecond},
+			other:   FullnodeNode{healthy: true, height: 100, latency: 100 * time.Millisecond},
+			keep:    false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			current, other := test.current, test.other
+			current.ws, other.ws = "current", "other"
+			pool := &FullnodePool{nodes: []*FullnodeNode{&current, &other}}
+
+			if keep := pool.isBestWs("current"); keep != test.keep {
+				t.Errorf("isBestWs() = %t, want %t", keep, test.keep)
+			}
+		})
+	}
+}
@@ -17,13 +17,19 @@ func getTradesGate(from int64, to int64, chMessagesCex chan MessageCex) {
 	ctx := context.Background()
 	currencyPair := "ALPH_USDT" // string - Currency pair
 
-	result, _, err := client.SpotApi.ListTrades(ctx, currencyPair, &gateapi.ListTradesOpts{From: optional.NewInt64(from), To: optional.NewInt64(to), Limit: optional.NewInt32(1000)})
+	result, resp, err := client.SpotApi.ListTrades(ctx, currencyPair, &gateapi.ListTradesOpts{From: optional.NewInt64(from), To: optional.NewInt64(to), Limit: optional.NewInt32(1000)})
 	if err != nil {
+		statusCode := 0
+		if resp != nil {
+			statusCode = resp.StatusCode
+		}
+		httpErr := newHttpError(client.GetConfig().BasePath+"/spot/trades", statusCode, err)
+
 		if e, ok := err.(gateapi.GateAPIError); ok {
-			log.Printf("gate api error: %s\n", e.Error())
+			log.Printf("gate api error: %s, class: %s\n", e.Error(), httpErr.Class)
 			return
 		} else {
-			log.Printf("generic error: %s\n", err.Error())
+			log.Printf("generic error: %s\n", httpErr)
 			return
 		}
 	}
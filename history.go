@@ -0,0 +1,138 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// alerts older than that are forgotten, it bounds the window of the bot queries
+	alertHistoryMaxAge  = 7 * 24 * time.Hour
+	alertHistoryMaxSize = 5000
+)
+
+type AlertRecord struct {
+	time time.Time
+	msg  Message
+}
+
+// AlertHistory keeps the recent on-chain alerts, one per transaction and asset, for the bot queries
+type AlertHistory struct {
+	mu      sync.Mutex
+	records []AlertRecord
+}
+
+var alertHistory = &AlertHistory{}
+
+// historyKey identifies an alert within its transaction, by kind, asset and receiver, so the swaps, bridge
+// transfers and transfers of several assets of one transaction are all kept
+func historyKey(msg Message) string {
+	kind := "transfer"
+	switch {
+	case msg.swap != nil:
+		kind = "swap"
+	case msg.bridge != nil:
+		kind = "bridge"
+	}
+
+	tokenId, _, _ := msg.asset()
+	return msg.txId + "/" + kind + "/" + tokenId + "/" + msg.to
+}
+
+// add records the alert, a later alert of the same transaction and asset, e.g. the confirmed alert of a mempool
+// alert, replaces the previous one. Status updates are not recorded
+func (h *AlertHistory) add(msg Message) {
+	if msg.followUp {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if msg.event == nil {
+		key := historyKey(msg)
+		for i := range h.records {
+			if h.records[i].msg.event == nil && historyKey(h.records[i].msg) == key {
+				h.records[i].msg = msg
+				return
+			}
+		}
+	}
+
+	h.records = append(h.records, AlertRecord{time: time.Now(), msg: msg})
+	h.prune()
+}
+
+func (h *AlertHistory) prune() {
+	cutoff := time.Now().Add(-alertHistoryMaxAge)
+	start := 0
+	for start < len(h.records) && (h.records[start].time.Before(cutoff) || len(h.records)-start > alertHistoryMaxSize) {
+		start++
+	}
+	h.records = h.records[start:]
+}
+
+// since returns the alerts of the window, oldest first
+func (h *AlertHistory) since(window time.Duration) []AlertRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cutoff := time.Now().Add(-window)
+	var records []AlertRecord
+	for _, record := range h.records {
+		if record.time.After(cutoff) {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
+// byAddress returns the alerts involving the address, newest first
+func (h *AlertHistory) byAddress(address string, limit int) []AlertRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var records []AlertRecord
+	for i := len(h.records) - 1; i >= 0 && len(records) < limit; i-- {
+		if h.records[i].msg.involves(address) {
+			records = append(records, h.records[i])
+		}
+	}
+
+	return records
+}
+
+// byTx returns the alerts of the transaction, oldest first
+func (h *AlertHistory) byTx(txId string) []AlertRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var records []AlertRecord
+	for _, record := range h.records {
+		if record.msg.txId == txId {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
+// involves returns true if the address is a sender, a receiver, the swap trader or the bridge user
+func (msg Message) involves(address string) bool {
+	if msg.from == address || msg.to == address {
+		return true
+	}
+
+	for _, addresses := range [][]string{msg.fromAddresses, msg.toAddresses} {
+		for _, a := range addresses {
+			if a == address {
+				return true
+			}
+		}
+	}
+
+	return (msg.swap != nil && msg.swap.trader == address) ||
+		(msg.bridge != nil && msg.bridge.user == address) ||
+		(msg.event != nil && msg.event.address == address)
+}
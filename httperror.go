@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+)
+
+type HttpErrorClass string
+
+const (
+	httpErrorTimeout   HttpErrorClass = "timeout"
+	httpErrorTransport HttpErrorClass = "transport"
+	httpErrorClient    HttpErrorClass = "4xx"
+	httpErrorServer    HttpErrorClass = "5xx"
+	httpErrorDecode    HttpErrorClass = "decode"
+)
+
+// HttpError is a failed HTTP query, classified so callers and alerts can tell a timeout from a bad response
+type HttpError struct {
+	Class      HttpErrorClass
+	Url        string
+	StatusCode int
+	Err        error
+}
+
+func (e *HttpError) Error() string {
+	if e.StatusCode > 0 {
+		return fmt.Sprintf("%s error with url: %s, status %d: %s", e.Class, e.Url, e.StatusCode, e.Err)
+	}
+
+	return fmt.Sprintf("%s error with url: %s: %s", e.Class, e.Url, e.Err)
+}
+
+func (e *HttpError) Unwrap() error {
+	return e.Err
+}
+
+// newHttpError classifies the error of a query and counts it, statusCode is 0 when there is no response
+func newHttpError(queryUrl string, statusCode int, err error) *HttpError {
+	httpErr := &HttpError{Url: queryUrl, StatusCode: statusCode, Err: err}
+
+	var netErr net.Error
+	switch {
+	case statusCode >= 500:
+		httpErr.Class = httpErrorServer
+	case statusCode >= 400:
+		httpErr.Class = httpErrorClient
+	case errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		httpErr.Class = httpErrorTimeout
+	default:
+		httpErr.Class = httpErrorTransport
+	}
+
+	httpErrorsMetric.WithLabelValues(urlHost(queryUrl), string(httpErr.Class)).Inc()
+	return httpErr
+}
+
+// decodeJson unmarshals a response, failures are counted as decode errors
+func decodeJson(queryUrl string, dataBytes []byte, value any) error {
+	err := json.Unmarshal(dataBytes, value)
+	if err != nil {
+		httpErrorsMetric.WithLabelValues(urlHost(queryUrl), string(httpErrorDecode)).Inc()
+		return &HttpError{Class: httpErrorDecode, Url: queryUrl, Err: err}
+	}
+
+	return nil
+}
+
+func urlHost(queryUrl string) string {
+	parsed, err := url.Parse(queryUrl)
+	if err != nil || parsed.Host == "" {
+		return "unknown"
+	}
+
+	return parsed.Host
+}
@@ -1,24 +1,34 @@
 package main
 
 import (
+	"context"
 	"log"
 	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-co-op/gocron"
-	"github.com/michimani/gotwi"
-	"github.com/mymmrac/telego"
-	ta "github.com/mymmrac/telego/telegoapi"
 )
 
 type Message struct {
-	from        string
-	to          string
-	amountChain float64
-	txId        string
-	tokenData   Token
-	groupFrom   int
-	groupTo     int
+	from          string
+	to            string
+	fromAddresses []string
+	toAddresses   []string
+	amountChain   float64
+	txId          string
+	tokenData     Token
+	groupFrom     int
+	groupTo       int
+	blockHash     string
+	height        int
+	status        AlertStatus
+	followUp      bool
+	swap          *SwapData
+	event         *EventData
+	bridge        *BridgeData
 }
 
 type Tx struct {
@@ -26,6 +36,9 @@ type Tx struct {
 	height    int
 	groupFrom int
 	groupTo   int
+	blockHash string
+	txData    *Transaction
+	status    AlertStatus
 }
 
 type MessageCex struct {
@@ -42,25 +55,44 @@ type CexSymbol struct {
 }
 
 type Parameters struct {
-	TelegramChatId           int64
-	TelegramTokenApi         string
-	TwitterAccessToken       string
-	TwitterAccessTokenSecret string
-	ExplorerApi              string
-	FullnodeApi              string
-	WsFullnode               string
-	FrontendExplorerUrl      string
-	MinAmountTrigger         float64
-	MinAmountCexTriggerUsd   float64
-	debugMode                bool
-	PollingIntervalSec       int64
-	KnownWalletUrl           string
-	PriceUrl                 string
-	TokenListUrl             string
+	TelegramChatId            int64
+	TelegramRoutes            []TelegramRoute
+	TelegramTokenApi          string
+	TelegramCommands          bool
+	TwitterAccessToken        string
+	TwitterAccessTokenSecret  string
+	ExplorerApi               string
+	ExplorerRateLimit         float64
+	ExplorerCacheSize         int
+	FullnodeApi               string
+	WsFullnode                string
+	FrontendExplorerUrl       string
+	MinAmountTrigger          float64
+	MinAmountCexTriggerUsd    float64
+	MinAmountSwapTrigger      float64
+	debugMode                 bool
+	PollingIntervalSec        int64
+	KnownWalletUrl            string
+	PriceUrl                  string
+	TokenListUrl              string
+	CursorFile                string
+	ConfirmationDepth         int
+	MempoolPollingIntervalSec int64
+	SeverityDepths            []SeverityDepth
+	ContractsFile             string
+	EventsCounterFile         string
+	EventsPollingIntervalSec  int64
+	BridgeContracts           map[string]string
+	BridgeCoreContract        string
+	MinAmountBridgeTriggerUsd float64
+	Notifiers                 []string
+	UndeliveredFile           string
+	SubscriptionsFile         string
+	WebhookUrl                string
+	WebhookSecret             string
+	WebhookDeadLetterFile     string
 }
 
-var telegramBot *telego.Bot
-var twitterBot *gotwi.Client
 var KnownWallets map[string]KnownWallet
 var Tokens TokenList
 
@@ -71,14 +103,31 @@ func main() {
 	loadEnv()
 	loadTokensToTrack()
 
+	fullnodePool = NewFullnodePool(parameters.FullnodeApi, parameters.WsFullnode)
+	fullnodePool.probe()
+	fullnodeClient = NewFullnodeClient(fullnodePool, fullnodeTimeout)
+	explorerClient = NewExplorerClient(parameters.ExplorerApi, parameters.ExplorerRateLimit, parameters.ExplorerCacheSize)
+
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		runBackfill(os.Args[2:])
+		return
+	}
+
 	updateTokens()
 	updateKnownWallet()
 
 	go metricsHttp()
+
+	notifiers = NewNotifierRegistry(parameters.Notifiers)
+
 	cronScheduler := gocron.NewScheduler(time.UTC)
 
 	cronScheduler.Every("5m").Do(updatePrice)
-	cronScheduler.Every("1h").Do(updateKnownWallet)
+	// alerts already sent are updated with the new known wallets
+	cronScheduler.Every("1h").Do(func() {
+		updateKnownWallet()
+		notifiers.refresh()
+	})
 	cronScheduler.Every("1h").Do(updateTokens)
 	cronScheduler.StartAsync()
 	rand.NewSource(time.Now().UnixNano())
@@ -99,42 +148,94 @@ func main() {
 	chMessagesCex := make(chan MessageCex, cexQueueSize)
 	chTxs := make(chan Tx, txQueueSize)
 
-	telegramBot = initTelegram()
-	var err error
-	twitterBot, err = initTwitter()
-	if err != nil {
-		log.Printf("cannot init twitter, err: %s\n", err)
-		twitterBot = nil
-	}
+	// producers stop on SIGINT or SIGTERM, the queues are then drained stage by stage
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
+	txsStage := NewStage()
+	messagesStage := NewStage()
 	for w := 1; w <= maxWorkersTxs; w++ {
-		go checkTx(chTxs, chMessages, w)
-		go messageConsumer(chMessagesCex, chMessages)
+		txsStage.wg.Add(1)
+		go checkTx(txsStage, chTxs, chMessages, w)
+		messagesStage.wg.Add(2)
+		go messageConsumer(messagesStage, chMessages)
+		go cexMessageConsumer(messagesStage, chMessagesCex)
+	}
+
+	publishUndelivered(parameters.UndeliveredFile)
+
+	// one bot answers the commands and sends the alerts of the personal watchlists
+	if parameters.TelegramCommands {
+		bot, err := initTelegram()
+		if err != nil {
+			log.Printf("cannot init telegram bot for commands, err: %s\n", err)
+		} else {
+			if err := subscriptions.load(parameters.SubscriptionsFile); err != nil {
+				log.Printf("cannot load subscriptions, err: %s\n", err)
+			}
+			privateAlerts = NewPrivateAlerts(bot)
+			go runTelegramCommands(ctx, bot)
+		}
 	}
 
 	if parameters.debugMode {
 		testsAlert(chTxs)
 	}
 
-	go getCexTrades(chMessagesCex)
-	getBlocksFullnode(chTxs)
+	err := chainCursor.load(parameters.CursorFile)
+	if err != nil {
+		log.Printf("cannot load cursor, starting from live blocks, err: %s\n", err)
+	}
+	go saveCursorLoop(ctx, parameters.CursorFile)
+	go fullnodePool.probeLoop(ctx)
+	go confirmations.run(ctx)
 
-}
+	if parameters.MempoolPollingIntervalSec > 0 {
+		go getMempoolTxs(ctx, chMessages)
+	}
 
-func checkTx(ch chan Tx, msgCh chan Message, wId int) {
-	for {
+	if parameters.ContractsFile != "" {
+		go getContractEventsLoop(ctx, chMessages)
+	}
 
-		select {
-		case tx := <-ch:
-			getTxData(tx, msgCh, wId)
-			txQueueMetrics.Dec()
-		default:
-			time.Sleep(500 * time.Millisecond)
-		}
+	go getCexTrades(ctx, chMessagesCex)
+	getBlocksFullnode(ctx, chTxs)
+
+	log.Printf("Shutting down, draining queues for at most %s\n", shutdownTimeout)
+	cronScheduler.Stop()
+	startDrain()
+	taskQueue.stage.close()
+	txsStage.close()
+	messagesStage.close()
+	if privateAlerts != nil {
+		privateAlerts.close()
+	}
+	notifiers.close()
+
+	saved, err := saveUndelivered(parameters.UndeliveredFile, chMessages, chMessagesCex)
+	if err != nil {
+		log.Printf("Error saving undelivered alerts, err: %s\n", err)
+	} else if saved > 0 {
+		log.Printf("Saved %d undelivered alerts\n", saved)
 	}
+
+	if err := chainCursor.save(parameters.CursorFile); err != nil {
+		log.Printf("Error saving cursor, err: %s\n", err)
+	}
+
+}
+
+// checkTx checks the transactions of the queue, once the stage is stopped it leaves when the queue is empty
+func checkTx(stage *Stage, ch chan Tx, msgCh chan Message, wId int) {
+	defer stage.wg.Done()
+
+	consume(stage, ch, func(tx Tx) {
+		getTxData(tx, msgCh, wId)
+		txQueueMetrics.Dec()
+	})
 }
 
-func getCexTrades(msgCh chan MessageCex) {
+func getCexTrades(ctx context.Context, msgCh chan MessageCex) {
 	for {
 		t := time.Now().Unix()
 		mexcSymbols := []string{"ALPH"}
@@ -144,67 +245,30 @@ func getCexTrades(msgCh chan MessageCex) {
 		go getTradesBitget(t*1000-parameters.PollingIntervalSec*1000, t*1000, msgCh)
 
 		log.Println("CEX - Sleepy sleepy")
-		time.Sleep(time.Duration(parameters.PollingIntervalSec) * time.Second)
+		if !sleepCtx(ctx, time.Duration(parameters.PollingIntervalSec)*time.Second) {
+			return
+		}
 	}
 
 }
 
-func initTelegram() *telego.Bot {
-	bot, err := telego.NewBot(parameters.TelegramTokenApi, telego.WithAPICaller(&ta.RetryCaller{
-		// Use caller
-		Caller: ta.DefaultFastHTTPCaller,
-		// Max number of attempts to make call
-		MaxAttempts: 15,
-		// Exponent base for delay
-		ExponentBase: 2,
-		// Starting delay duration
-		StartDelay: time.Millisecond * 45,
-		// Maximum delay duration
-		MaxDelay: time.Second * 120,
-	}))
-	if nil != err {
-		// panics for the sake of simplicity.
-		// you should handle this error properly in your code.
-		panic(err)
-	}
+// messageConsumer sends the alerts, once the stage is stopped it leaves when the queue is empty
+func messageConsumer(stage *Stage, chMessages chan Message) {
+	defer stage.wg.Done()
 
-	return bot
+	consume(stage, chMessages, func(msg Message) {
+		alertHistory.add(msg)
+		notifiers.publish(newNotification(msg))
+		notificationQueueMetric.Dec()
+	})
 }
 
-func initTwitter() (*gotwi.Client, error) {
-	in := &gotwi.NewClientInput{
-		AuthenticationMethod: gotwi.AuthenMethodOAuth1UserContext,
-		OAuthToken:           parameters.TwitterAccessToken,
-		OAuthTokenSecret:     parameters.TwitterAccessTokenSecret,
-	}
-
-	return gotwi.NewClient(in)
-}
+// cexMessageConsumer sends the CEX trades alerts, once the stage is stopped it leaves when the queue is empty
+func cexMessageConsumer(stage *Stage, chMessagesCex chan MessageCex) {
+	defer stage.wg.Done()
 
-func messageConsumer(chMessagesCex chan MessageCex, chMessages chan Message) {
-
-	for {
-
-		select {
-		case msg := <-chMessagesCex:
-			sendTelegramMessage(telegramBot, parameters.TelegramChatId, formatCexMessage(msg))
-
-			if twitterBot != nil {
-				sendTwitterPost(twitterBot, formatCexMessage(msg))
-			}
-			//formatCexMessage(<-chMessagesCex)
-			cexQueueMetrics.Dec()
-		case msg := <-chMessages:
-			sendTelegramMessage(telegramBot, parameters.TelegramChatId, messageFormat(msg, true))
-
-			if twitterBot != nil {
-				sendTwitterPost(twitterBot, messageFormat(msg, false))
-			}
-			notificationQueueMetric.Dec()
-		//telegramMessageFormat(<-chMessages)
-		default:
-			time.Sleep(500 * time.Millisecond)
-		}
-
-	}
+	consume(stage, chMessagesCex, func(msg MessageCex) {
+		notifiers.publish(newCexNotification(msg))
+		cexQueueMetrics.Dec()
+	})
 }
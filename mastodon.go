@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// Mastodon allows 300 statuses per 3 hours for an account
+const mastodonRateLimit = 300.0 / (3 * 60 * 60)
+
+type MastodonStatus struct {
+	Status     string `json:"status"`
+	Visibility string `json:"visibility"`
+}
+
+type MastodonNotifier struct {
+	instance    string
+	accessToken string
+	visibility  string
+	client      *retryablehttp.Client
+}
+
+func init() {
+	registerNotifier("mastodon", newMastodonNotifier)
+}
+
+func newMastodonNotifier() (Notifier, error) {
+	instance := strings.TrimSuffix(os.Getenv("MASTODON_URL"), "/")
+	accessToken := os.Getenv("MASTODON_ACCESS_TOKEN")
+	if instance == "" || accessToken == "" {
+		return nil, fmt.Errorf("MASTODON_URL and MASTODON_ACCESS_TOKEN are not set")
+	}
+
+	visibility := os.Getenv("MASTODON_VISIBILITY")
+	if visibility == "" {
+		visibility = "public"
+	}
+
+	return &MastodonNotifier{instance: instance, accessToken: accessToken, visibility: visibility, client: newHttpClient()}, nil
+}
+
+func (m *MastodonNotifier) Name() string {
+	return "mastodon"
+}
+
+func (m *MastodonNotifier) Format() Format {
+	return formatText
+}
+
+// MaxLength is the default limit of the instances, links count for 23 characters so the text always fits
+func (m *MastodonNotifier) MaxLength() int {
+	return 500
+}
+
+func (m *MastodonNotifier) RateLimit() float64 {
+	return mastodonRateLimit
+}
+
+func (m *MastodonNotifier) Send(ctx context.Context, text string) error {
+	body, err := json.Marshal(MastodonStatus{Status: text, Visibility: m.visibility})
+	if err != nil {
+		return fmt.Errorf("cannot marshal mastodon status: %w", err)
+	}
+
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPost, m.instance+"/api/v1/statuses", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create mastodon request: %w", err)
+	}
+	// the instance drops retries of a status it already posted
+	idempotencyKey := sha256.Sum256([]byte(text))
+	req.Header.Set("Idempotency-Key", hex.EncodeToString(idempotencyKey[:]))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.accessToken)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return newHttpError(m.instance, 0, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return newHttpError(m.instance, resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, respBody))
+	}
+
+	return nil
+}
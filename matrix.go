@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// homeservers rate limit clients to a few messages per second
+const matrixRateLimit = 0.5
+
+// MatrixMessage is an m.room.message event with an HTML body
+type MatrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+type MatrixNotifier struct {
+	homeserver  string
+	accessToken string
+	rooms       []FilteredTarget
+	client      *retryablehttp.Client
+	// transaction ids must be unique for the access token, the start time separates restarts
+	txnPrefix string
+	txnCount  atomic.Int64
+}
+
+func init() {
+	registerNotifier("matrix", newMatrixNotifier)
+}
+
+func newMatrixNotifier() (Notifier, error) {
+	homeserver := strings.TrimSuffix(os.Getenv("MATRIX_HOMESERVER"), "/")
+	accessToken := os.Getenv("MATRIX_ACCESS_TOKEN")
+	if homeserver == "" || accessToken == "" {
+		return nil, fmt.Errorf("MATRIX_HOMESERVER and MATRIX_ACCESS_TOKEN are not set")
+	}
+
+	rooms := loadFilteredTargets("MATRIX_ROOMS")
+	if len(rooms) == 0 {
+		return nil, fmt.Errorf("MATRIX_ROOMS is not set")
+	}
+
+	return &MatrixNotifier{
+		homeserver:  homeserver,
+		accessToken: accessToken,
+		rooms:       rooms,
+		client:      newHttpClient(),
+		txnPrefix:   fmt.Sprintf("whale-%d", time.Now().UnixNano()),
+	}, nil
+}
+
+func (m *MatrixNotifier) Name() string {
+	return "matrix"
+}
+
+func (m *MatrixNotifier) Format() Format {
+	return formatHtml
+}
+
+func (m *MatrixNotifier) MaxLength() int {
+	return 0
+}
+
+func (m *MatrixNotifier) RateLimit() float64 {
+	return matrixRateLimit
+}
+
+// Send posts an HTML alert restored from the undelivered file to every room
+func (m *MatrixNotifier) Send(ctx context.Context, text string) error {
+	return m.SendNotification(ctx, Notification{html: text, text: htmlLinkRegexp.ReplaceAllString(text, "$2: $1")})
+}
+
+// SendNotification posts the alert to the rooms whose filter matches, clients without HTML support show the
+// text alert
+func (m *MatrixNotifier) SendNotification(ctx context.Context, notification Notification) error {
+	message := MatrixMessage{
+		MsgType:       "m.notice",
+		Body:          notification.text,
+		Format:        "org.matrix.custom.html",
+		FormattedBody: strings.ReplaceAll(notification.html, "\n", "<br>"),
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("cannot marshal matrix message: %w", err)
+	}
+
+	var errs []error
+	for _, room := range m.rooms {
+		if !room.filter.match(notification) {
+			continue
+		}
+
+		if err := m.post(ctx, room.value, body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *MatrixNotifier) post(ctx context.Context, roomId string, body []byte) error {
+	txnId := fmt.Sprintf("%s-%d", m.txnPrefix, m.txnCount.Add(1))
+	sendUrl := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s", m.homeserver, url.PathEscape(roomId), txnId)
+
+	// the transaction id makes retries of the PUT idempotent
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPut, sendUrl, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create matrix request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.accessToken)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return newHttpError(m.homeserver, 0, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return newHttpError(m.homeserver, resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, respBody))
+	}
+
+	return nil
+}
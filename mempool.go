@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// how long a pending alert is kept to be linked with its confirmed transaction
+const pendingAlertTtl = 1 * time.Hour
+
+type pendingAlert struct {
+	msg    Message
+	sentAt time.Time
+}
+
+// PendingAlerts keeps alerts sent for mempool transactions to not send them twice once mined
+type PendingAlerts struct {
+	mu      sync.Mutex
+	alerts  map[string]pendingAlert
+	checked map[string]time.Time
+}
+
+var pendingAlerts = &PendingAlerts{
+	alerts:  make(map[string]pendingAlert),
+	checked: make(map[string]time.Time),
+}
+
+func pendingAlertKey(msg Message) string {
+	return msg.txId + msg.to + msg.tokenData.ID
+}
+
+// add records an alert sent for a mempool transaction
+func (p *PendingAlerts) add(msg Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.alerts[pendingAlertKey(msg)] = pendingAlert{msg, time.Now()}
+}
+
+// link returns true if an alert was already sent for this transfer while it was in the mempool
+func (p *PendingAlerts) link(msg Message) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := pendingAlertKey(msg)
+	_, ok := p.alerts[key]
+	if ok && msg.status == statusConfirmed {
+		delete(p.alerts, key)
+	}
+
+	return ok
+}
+
+// markChecked returns false if the mempool transaction was already checked
+func (p *PendingAlerts) markChecked(txId string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.checked[txId]; ok {
+		return false
+	}
+	p.checked[txId] = time.Now()
+
+	return true
+}
+
+func (p *PendingAlerts) cleanup() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, alert := range p.alerts {
+		if time.Since(alert.sentAt) > pendingAlertTtl {
+			delete(p.alerts, key)
+		}
+	}
+
+	for txId, checkedAt := range p.checked {
+		if time.Since(checkedAt) > pendingAlertTtl {
+			delete(p.checked, txId)
+		}
+	}
+}
+
+// getMempoolTxs polls the fullnode mempool and checks each new transaction against the triggers
+func getMempoolTxs(ctx context.Context, chMessages chan Message) {
+	for {
+		checkMempool(chMessages)
+		pendingAlerts.cleanup()
+
+		if !sleepCtx(ctx, time.Duration(parameters.MempoolPollingIntervalSec)*time.Second) {
+			return
+		}
+	}
+}
+
+func checkMempool(chMessages chan Message) {
+	mempool, err := fullnodeClient.mempoolTransactions(context.Background())
+	if err != nil {
+		log.Printf("Error getting mempool\n%s\n", err)
+		return
+	}
+
+	for _, chain := range mempool {
+		for _, mempoolTx := range chain.Transactions {
+			if len(mempoolTx.Unsigned.Inputs) == 0 || !pendingAlerts.markChecked(mempoolTx.Unsigned.TxId) {
+				continue
+			}
+
+			// sender is derived from the first input, as for confirmed transactions
+			addressIn, err := addressFromUnlockScript(mempoolTx.Unsigned.Inputs[0].UnlockScript)
+			if err != nil {
+				if parameters.debugMode {
+					log.Printf("cannot get sender of mempool tx %s, err: %s\n", mempoolTx.Unsigned.TxId, err)
+				}
+				continue
+			}
+
+			txData := Transaction{Type: "Pending", Hash: mempoolTx.Unsigned.TxId}
+			for i, input := range mempoolTx.Unsigned.Inputs {
+				txInput := TxInput{OutputRef: OutputRef{Hint: int(input.OutputRef.Hint), Key: input.OutputRef.Key}, UnlockScript: input.UnlockScript}
+				if i == 0 {
+					txInput.Address = addressIn
+				}
+				txData.Inputs = append(txData.Inputs, txInput)
+			}
+
+			for _, output := range mempoolTx.Unsigned.FixedOutputs {
+				txData.Outputs = append(txData.Outputs, TxOutput{
+					Type:           "AssetOutput",
+					Hint:           int(output.Hint),
+					Key:            output.Key,
+					AttoAlphAmount: output.AttoAlphAmount,
+					Address:        output.Address,
+					Tokens:         tokenAmountsFromSdk(output.Tokens),
+					Message:        output.Message,
+				})
+			}
+
+			mempoolTxsMetric.Inc()
+			checkTransaction(&txData, Tx{id: txData.Hash, groupFrom: int(chain.FromGroup), groupTo: int(chain.ToGroup), status: statusPending}, chMessages)
+		}
+	}
+}
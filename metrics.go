@@ -31,6 +31,20 @@ var (
 	})
 )
 
+var (
+	droppedTasksMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "whales_watcher_dropped_blocks_total",
+		Help: "The total number of blocks dropped because the queue was full",
+	})
+)
+
+var (
+	spilledTasksMetric = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "whales_watcher_spilled_blocks",
+		Help: "Number of blocks waiting for room in the queue to be fetched again",
+	})
+)
+
 var (
 	inqueueMetrics = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "whales_watcher_inqueue_total",
@@ -101,6 +115,234 @@ var (
 	})
 )
 
+var (
+	wsReconnectsMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "whales_watcher_ws_reconnects_total",
+		Help: "The total number of reconnections to the fullnode websocket",
+	})
+)
+
+var (
+	wsConnectedMetric = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "whales_watcher_ws_connected",
+		Help: "1 if connected to the fullnode websocket",
+	})
+)
+
+var (
+	gapFillBlocksMetrics = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "whales_watcher_gap_fill_blocks_total",
+		Help: "The total number of blocks fetched from the fullnode after a reconnection",
+	})
+)
+
+var (
+	gapFillSizeMetrics = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "whales_watcher_gap_fill_size",
+		Help:    "Number of blocks fetched per chain after a reconnection",
+		Buckets: []float64{0, 1, 5, 10, 50, 100, 500, 1000},
+	})
+)
+
+var (
+	fullnodeDecodedTxsMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "whales_watcher_fullnode_decoded_txs_total",
+		Help: "The total number of transactions decoded from the block payload and the fullnode",
+	})
+)
+
+var (
+	explorerTxsMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "whales_watcher_explorer_txs_total",
+		Help: "The total number of transactions fetched from the explorer",
+	})
+	swapsUndecodedMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "whales_watcher_swaps_undecoded_txs_total",
+		Help: "The total number of contract transactions from the explorer whose contract inputs could not be resolved",
+	})
+)
+
+var (
+	explorerRequestsMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "whales_watcher_explorer_requests_total",
+		Help: "The total number of explorer requests per endpoint and status",
+	}, []string{"endpoint", "status"})
+)
+
+var (
+	explorerLatencyMetric = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "whales_watcher_explorer_latency_seconds",
+		Help:    "Latency of the explorer requests per endpoint",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint"})
+)
+
+var (
+	explorerCacheHitsMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "whales_watcher_explorer_cache_hits_total",
+		Help: "The total number of explorer responses served from the cache",
+	})
+)
+
+var (
+	explorerBreakerOpenMetric = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "whales_watcher_explorer_circuit_open",
+		Help: "1 if explorer requests are paused after consecutive failures",
+	})
+)
+
+var (
+	trackedBlocksMetric = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "whales_watcher_tracked_blocks",
+		Help: "Number of blocks waiting for confirmation",
+	})
+)
+
+var (
+	orphanedBlocksMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "whales_watcher_orphaned_blocks_total",
+		Help: "The total number of blocks dropped out of the main chain",
+	})
+)
+
+var (
+	mempoolTxsMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "whales_watcher_mempool_txs_total",
+		Help: "The total number of mempool transactions checked",
+	})
+)
+
+var (
+	swapsMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "whales_watcher_swaps_total",
+		Help: "The total number of DEX swaps alerts",
+	})
+)
+
+var (
+	contractEventsMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "whales_watcher_contract_events_total",
+		Help: "The total number of contract events checked",
+	})
+)
+
+var (
+	bridgeTransfersMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "whales_watcher_bridge_transfers_total",
+		Help: "The total number of bridge transfers alerts",
+	})
+
+	bridgeUnpricedMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "whales_watcher_bridge_unpriced_transfers_total",
+		Help: "The total number of bridge transfers without token price, alerted on the token trigger or skipped",
+	}, []string{"action"})
+)
+
+var (
+	fullnodeRequestsMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "whales_watcher_fullnode_requests_total",
+		Help: "The total number of requests per fullnode",
+	}, []string{"node"})
+)
+
+var (
+	fullnodeErrorsMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "whales_watcher_fullnode_errors_total",
+		Help: "The total number of failed requests per fullnode",
+	}, []string{"node"})
+)
+
+var (
+	fullnodeLatencyMetric = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "whales_watcher_fullnode_latency_seconds",
+		Help: "Latency of the last request per fullnode",
+	}, []string{"node"})
+)
+
+var (
+	fullnodeHealthyMetric = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "whales_watcher_fullnode_healthy",
+		Help: "1 if the fullnode is healthy",
+	}, []string{"node"})
+)
+
+var (
+	fullnodeLagMetric = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "whales_watcher_fullnode_lag",
+		Help: "Number of blocks the fullnode is behind the highest one",
+	}, []string{"node"})
+)
+
+var (
+	fullnodeFailoversMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "whales_watcher_fullnode_failovers_total",
+		Help: "The total number of requests retried on another fullnode",
+	})
+)
+
+var (
+	httpErrorsMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "whales_watcher_http_errors_total",
+		Help: "The total number of failed HTTP queries per host and class (timeout, transport, 4xx, 5xx, decode)",
+	}, []string{"host", "class"})
+)
+
+var (
+	notifierSentMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "whales_watcher_notifier_sent_total",
+		Help: "The total number of alerts sent per sink",
+	}, []string{"sink"})
+)
+
+var (
+	notifierErrorsMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "whales_watcher_notifier_errors_total",
+		Help: "The total number of alerts which failed to be sent per sink",
+	}, []string{"sink"})
+)
+
+var (
+	notifierDroppedMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "whales_watcher_notifier_dropped_total",
+		Help: "The total number of alerts dropped because the queue of the sink was full",
+	}, []string{"sink"})
+)
+
+var (
+	notifierQueueMetric = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "whales_watcher_notifier_queue",
+		Help: "Number of alerts waiting in the queue of each sink",
+	}, []string{"sink"})
+)
+
+var (
+	commandsMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "whales_watcher_telegram_commands_total",
+		Help: "The total number of telegram commands answered",
+	}, []string{"command"})
+)
+
+var (
+	subscriptionsMetric = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "whales_watcher_subscriptions",
+		Help: "Number of addresses and tokens in the personal watchlists",
+	})
+)
+
+var (
+	privateAlertsMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "whales_watcher_private_alerts_total",
+		Help: "The total number of watchlist alerts per result, sent, error or dropped",
+	}, []string{"result"})
+)
+
+var (
+	telegramEditsMetric = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "whales_watcher_telegram_edits_total",
+		Help: "The total number of telegram alerts edited per result, edited or error",
+	}, []string{"result"})
+)
+
 func metricsHttp() {
 	http.Handle("/metrics", promhttp.Handler())
 	http.ListenAndServe(":2112", nil)
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"strconv"
@@ -26,14 +25,16 @@ func getTradesMexc(from int64, to int64, chMessagesCex chan MessageCex, symbols
 
 func getTrades(from int64, to int64, chMessagesCex chan MessageCex, symbol string) {
 	var trades MexcAggTrades
-	dataBytes, _, err := getHttp(fmt.Sprintf("https://api.mexc.com/api/v3/trades/?symbol=%sUSDT", symbol))
+	url := fmt.Sprintf("https://api.mexc.com/api/v3/trades/?symbol=%sUSDT", symbol)
+	dataBytes, _, err := getHttp(url)
 	if err != nil {
-		log.Printf("Error getting price\n%s\n", err)
+		log.Printf("Error getting mexc trades, err: %s\n", err)
 		return
 	}
 
-	if len(dataBytes) > 0 {
-		json.Unmarshal(dataBytes, &trades)
+	if err := decodeJson(url, dataBytes, &trades); err != nil {
+		log.Printf("Error parsing mexc trades, err: %s\n", err)
+		return
 	}
 
 	for _, v := range trades {
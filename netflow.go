@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// id used for ALPH in net flows
+const alphFlowId = ""
+
+type AddressAmount struct {
+	address string
+	amount  *big.Int
+}
+
+// AssetFlow is the net movement of an asset (ALPH or token) in a transaction
+type AssetFlow struct {
+	tokenId    string
+	senders    []AddressAmount
+	recipients []AddressAmount
+	amount     *big.Int
+}
+
+// computeNetFlows computes the balance delta of each address for ALPH and each token across all inputs and
+// outputs of the transaction. When input amounts are unknown (mempool), input addresses are the senders and
+// outputs to other addresses are the recipients
+func computeNetFlows(txData *Transaction) ([]AssetFlow, error) {
+	deltas := make(map[string]map[string]*big.Int)
+	inputAddresses := make(map[string]bool)
+	inputAmountsKnown := true
+
+	addDelta := func(tokenId string, address string, amountStr string, sign int) error {
+		amount, ok := new(big.Int).SetString(amountStr, 10)
+		if !ok {
+			return fmt.Errorf("cannot parse amount %s", amountStr)
+		}
+		if sign < 0 {
+			amount.Neg(amount)
+		}
+
+		if deltas[tokenId] == nil {
+			deltas[tokenId] = make(map[string]*big.Int)
+		}
+		if deltas[tokenId][address] == nil {
+			deltas[tokenId][address] = new(big.Int)
+		}
+		deltas[tokenId][address].Add(deltas[tokenId][address], amount)
+
+		return nil
+	}
+
+	for _, input := range txData.Inputs {
+		if input.Address == "" {
+			continue
+		}
+		inputAddresses[input.Address] = true
+
+		if input.AttoAlphAmount == "" {
+			inputAmountsKnown = false
+			continue
+		}
+
+		if err := addDelta(alphFlowId, input.Address, input.AttoAlphAmount, -1); err != nil {
+			return nil, err
+		}
+		for _, token := range input.Tokens {
+			if err := addDelta(token.ID, input.Address, token.Amount, -1); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, output := range txData.Outputs {
+		// without input amounts, outputs going back to an input address are change
+		if !inputAmountsKnown && inputAddresses[output.Address] {
+			continue
+		}
+
+		if err := addDelta(alphFlowId, output.Address, output.AttoAlphAmount, 1); err != nil {
+			return nil, err
+		}
+		for _, token := range output.Tokens {
+			if err := addDelta(token.ID, output.Address, token.Amount, 1); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var flows []AssetFlow
+	for tokenId, addressDeltas := range deltas {
+		flow := AssetFlow{tokenId: tokenId, amount: new(big.Int)}
+
+		for address, delta := range addressDeltas {
+			switch delta.Sign() {
+			case 1:
+				flow.recipients = append(flow.recipients, AddressAmount{address, delta})
+				flow.amount.Add(flow.amount, delta)
+			case -1:
+				flow.senders = append(flow.senders, AddressAmount{address, new(big.Int).Neg(delta)})
+			}
+		}
+
+		if !inputAmountsKnown {
+			for address := range inputAddresses {
+				flow.senders = append(flow.senders, AddressAmount{address, new(big.Int)})
+			}
+		}
+
+		sortAddressAmounts(flow.senders)
+		sortAddressAmounts(flow.recipients)
+		flows = append(flows, flow)
+	}
+
+	// ALPH first, then tokens
+	sort.Slice(flows, func(i, j int) bool { return flows[i].tokenId < flows[j].tokenId })
+
+	return flows, nil
+}
+
+// sortAddressAmounts sorts by amount, biggest first
+func sortAddressAmounts(amounts []AddressAmount) {
+	sort.Slice(amounts, func(i, j int) bool {
+		cmp := amounts[i].amount.Cmp(amounts[j].amount)
+		if cmp == 0 {
+			return amounts[i].address < amounts[j].address
+		}
+		return cmp > 0
+	})
+}
+
+func addresses(amounts []AddressAmount) []string {
+	var list []string
+	for _, item := range amounts {
+		list = append(list, item.address)
+	}
+
+	return list
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// flowSummary is a comparable form of an AssetFlow
+type flowSummary struct {
+	tokenId    string
+	senders    []string
+	recipients []string
+	amount     string
+}
+
+func summarizeFlows(flows []AssetFlow) []flowSummary {
+	var summaries []flowSummary
+	for _, flow := range flows {
+		summary := flowSummary{tokenId: flow.tokenId, amount: flow.amount.String()}
+		for _, sender := range flow.senders {
+			summary.senders = append(summary.senders, sender.address+":"+sender.amount.String())
+		}
+		for _, recipient := range flow.recipients {
+			summary.recipients = append(summary.recipients, recipient.address+":"+recipient.amount.String())
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return summaries
+}
+
+func TestComputeNetFlows(t *testing.T) {
+	tests := []struct {
+		name string
+		tx   Transaction
+		want []flowSummary
+	}{
+		{
+			name: "change output",
+			tx: Transaction{
+				Inputs: []TxInput{{Address: "a", AttoAlphAmount: "1000"}},
+				Outputs: []TxOutput{
+					{Address: "b", AttoAlphAmount: "700"},
+					{Address: "a", AttoAlphAmount: "290"},
+				},
+			},
+			want: []flowSummary{
+				{tokenId: alphFlowId, senders: []string{"a:710"}, recipients: []string{"b:700"}, amount: "700"},
+			},
+		},
+		{
+			name: "multi sender",
+			tx: Transaction{
+				Inputs: []TxInput{
+					{Address: "a", AttoAlphAmount: "300"},
+					{Address: "b", AttoAlphAmount: "500"},
+					{Address: "a", AttoAlphAmount: "200"},
+				},
+				Outputs: []TxOutput{
+					{Address: "c", AttoAlphAmount: "900"},
+					{Address: "b", AttoAlphAmount: "90"},
+				},
+			},
+			want: []flowSummary{
+				{tokenId: alphFlowId, senders: []string{"a:500", "b:410"}, recipients: []string{"c:900"}, amount: "900"},
+			},
+		},
+		{
+			name: "token and ALPH",
+			tx: Transaction{
+				Inputs: []TxInput{
+					{Address: "a", AttoAlphAmount: "1000", Tokens: []TokenAmount{{ID: "usdt", Amount: "5000"}}},
+				},
+				Outputs: []TxOutput{
+					{Address: "b", AttoAlphAmount: "100", Tokens: []TokenAmount{{ID: "usdt", Amount: "4000"}}},
+					{Address: "a", AttoAlphAmount: "890", Tokens: []TokenAmount{{ID: "usdt", Amount: "1000"}}},
+				},
+			},
+			want: []flowSummary{
+				{tokenId: alphFlowId, senders: []string{"a:110"}, recipients: []string{"b:100"}, amount: "100"},
+				{tokenId: "usdt", senders: []string{"a:4000"}, recipients: []string{"b:4000"}, amount: "4000"},
+			},
+		},
+		{
+			name: "unknown input amounts",
+			tx: Transaction{
+				Inputs: []TxInput{{Address: "a"}, {Address: "b"}},
+				Outputs: []TxOutput{
+					{Address: "c", AttoAlphAmount: "600"},
+					{Address: "a", AttoAlphAmount: "400"},
+				},
+			},
+			want: []flowSummary{
+				{tokenId: alphFlowId, senders: []string{"a:0", "b:0"}, recipients: []string{"c:600"}, amount: "600"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flows, err := computeNetFlows(&test.tx)
+			if err != nil {
+				t.Fatalf("computeNetFlows() error: %s", err)
+			}
+
+			if got := summarizeFlows(flows); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("computeNetFlows() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestComputeNetFlowsInvalidAmount(t *testing.T) {
+	tx := Transaction{
+		Inputs:  []TxInput{{Address: "a", AttoAlphAmount: "1e18"}},
+		Outputs: []TxOutput{{Address: "b", AttoAlphAmount: "1"}},
+	}
+
+	if _, err := computeNetFlows(&tx); err == nil {
+		t.Error("computeNetFlows() accepted an invalid amount")
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// relays throttle clients publishing faster than a few notes per minute
+	nostrRateLimit = 0.2
+	nostrKindNote  = 1
+)
+
+// NostrEvent is a NIP-01 event
+type NostrEvent struct {
+	Id        string     `json:"id"`
+	PubKey    string     `json:"pubkey"`
+	CreatedAt int64      `json:"created_at"`
+	Kind      int        `json:"kind"`
+	Tags      [][]string `json:"tags"`
+	Content   string     `json:"content"`
+	Sig       string     `json:"sig"`
+}
+
+type NostrNotifier struct {
+	privateKey *btcec.PrivateKey
+	publicKey  string
+	relays     []string
+}
+
+func init() {
+	registerNotifier("nostr", newNostrNotifier)
+}
+
+func newNostrNotifier() (Notifier, error) {
+	relays := splitList(os.Getenv("NOSTR_RELAYS"))
+	if len(relays) == 0 {
+		return nil, fmt.Errorf("NOSTR_RELAYS is not set")
+	}
+
+	keyBytes, err := hex.DecodeString(os.Getenv("NOSTR_PRIVATE_KEY"))
+	if err != nil {
+		return nil, fmt.Errorf("NOSTR_PRIVATE_KEY is not a hex key: %w", err)
+	}
+
+	// PrivKeyFromBytes reduces the key modulo the curve order, out of range keys are rejected instead
+	var scalar btcec.ModNScalar
+	if len(keyBytes) != 32 || scalar.SetByteSlice(keyBytes) || scalar.IsZero() {
+		return nil, fmt.Errorf("NOSTR_PRIVATE_KEY is not a valid secp256k1 key")
+	}
+
+	privateKey, publicKey := btcec.PrivKeyFromBytes(keyBytes)
+
+	return &NostrNotifier{privateKey: privateKey, publicKey: hex.EncodeToString(schnorr.SerializePubKey(publicKey)), relays: relays}, nil
+}
+
+func (n *NostrNotifier) Name() string {
+	return "nostr"
+}
+
+func (n *NostrNotifier) Format() Format {
+	return formatText
+}
+
+func (n *NostrNotifier) MaxLength() int {
+	return 0
+}
+
+func (n *NostrNotifier) RateLimit() float64 {
+	return nostrRateLimit
+}
+
+// Send publishes a signed note to every relay, it fails only if no relay accepted it
+func (n *NostrNotifier) Send(ctx context.Context, text string) error {
+	event, err := n.signNote(text)
+	if err != nil {
+		return err
+	}
+
+	message, err := json.Marshal([]any{"EVENT", event})
+	if err != nil {
+		return fmt.Errorf("cannot marshal nostr event: %w", err)
+	}
+
+	var errs []error
+	for _, relay := range n.relays {
+		err := publishNostrEvent(ctx, relay, event.Id, message)
+		if err == nil {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("relay %s: %w", relay, err))
+	}
+
+	if len(errs) == len(n.relays) {
+		return errors.Join(errs...)
+	}
+
+	return nil
+}
+
+func (n *NostrNotifier) signNote(text string) (NostrEvent, error) {
+	event := NostrEvent{
+		PubKey:    n.publicKey,
+		CreatedAt: time.Now().Unix(),
+		Kind:      nostrKindNote,
+		Tags:      [][]string{{"t", "alephium"}},
+		Content:   text,
+	}
+
+	serialized, err := nostrJson([]any{0, event.PubKey, event.CreatedAt, event.Kind, event.Tags, event.Content})
+	if err != nil {
+		return event, fmt.Errorf("cannot serialize nostr event: %w", err)
+	}
+
+	id := sha256.Sum256(serialized)
+	sig, err := schnorr.Sign(n.privateKey, id[:])
+	if err != nil {
+		return event, fmt.Errorf("cannot sign nostr event: %w", err)
+	}
+
+	event.Id = hex.EncodeToString(id[:])
+	event.Sig = hex.EncodeToString(sig.Serialize())
+
+	return event, nil
+}
+
+// nostrJson serializes the event for its id, NIP-01 does not escape the HTML characters
+func nostrJson(value any) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
+// publishNostrEvent sends the event to the relay and waits for its OK message
+func publishNostrEvent(ctx context.Context, relay string, eventId string, message []byte) error {
+	connection, _, err := websocket.DefaultDialer.DialContext(ctx, relay, nil)
+	if err != nil {
+		return fmt.Errorf("cannot connect: %w", err)
+	}
+	defer connection.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		connection.SetReadDeadline(deadline)
+		connection.SetWriteDeadline(deadline)
+	}
+
+	if err := connection.WriteMessage(websocket.TextMessage, message); err != nil {
+		return fmt.Errorf("cannot send event: %w", err)
+	}
+
+	for {
+		_, data, err := connection.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("no OK from relay: %w", err)
+		}
+
+		// ["OK", <event id>, <accepted>, <message>], other messages like NOTICE are ignored
+		var response []json.RawMessage
+		if err := json.Unmarshal(data, &response); err != nil || len(response) < 3 {
+			continue
+		}
+
+		var kind, id string
+		var accepted bool
+		if json.Unmarshal(response[0], &kind) != nil || kind != "OK" || json.Unmarshal(response[1], &id) != nil || id != eventId {
+			continue
+		}
+		if err := json.Unmarshal(response[2], &accepted); err != nil {
+			return fmt.Errorf("cannot parse relay response: %w", err)
+		}
+
+		if !accepted {
+			var reason string
+			if len(response) > 3 {
+				json.Unmarshal(response[3], &reason)
+			}
+			return fmt.Errorf("event rejected: %s", reason)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// size of the queue of each sink, alerts are dropped when a sink is that much behind
+	notifierQueueSize = 100
+	// max time to deliver an alert to a sink
+	notifierSendTimeout = 30 * time.Second
+	// max time to update the alerts already sent
+	notifierRefreshTimeout = 10 * time.Minute
+)
+
+type Format int
+
+const (
+	// HTML with links, as used by Telegram
+	formatHtml Format = iota
+	// plain text, links are written in full
+	formatText
+)
+
+// Notification is an alert formatted in every format, with the alert itself for the sinks rendering it. Alerts
+// restored from the undelivered file only have their text
+type Notification struct {
+	html string
+	text string
+	msg  *Message
+	cex  *MessageCex
+}
+
+func newNotification(msg Message) Notification {
+	return Notification{html: messageFormat(msg, true), text: messageFormat(msg, false), msg: &msg}
+}
+
+func newCexNotification(msg MessageCex) Notification {
+	text := formatCexMessage(msg)
+	return Notification{html: text, text: text, cex: &msg}
+}
+
+func (n Notification) format(format Format) string {
+	if format == formatHtml {
+		return n.html
+	}
+
+	return n.text
+}
+
+// Notifier is a destination of the alerts
+type Notifier interface {
+	// Name identifies the sink in the config and the metrics
+	Name() string
+	Format() Format
+	// MaxLength is the max length of a message, 0 if there is no limit
+	MaxLength() int
+	// RateLimit is the max number of messages per second, 0 if there is no limit
+	RateLimit() float64
+	Send(ctx context.Context, text string) error
+}
+
+// RichNotifier is a sink rendering the alert itself instead of receiving its text
+type RichNotifier interface {
+	Notifier
+	SendNotification(ctx context.Context, notification Notification) error
+}
+
+// RenderingNotifier is a sink saving its own rendering of the alerts still queued at shutdown, which it receives
+// back as text at the next start
+type RenderingNotifier interface {
+	Notifier
+	Render(notification Notification) string
+}
+
+// RefreshingNotifier is a sink updating the alerts it already sent, after the known wallets are refreshed
+type RefreshingNotifier interface {
+	Notifier
+	Refresh(ctx context.Context)
+}
+
+// NotifierFactory creates a sink from the parameters
+type NotifierFactory func() (Notifier, error)
+
+var notifierFactories = make(map[string]NotifierFactory)
+
+// registerNotifier makes a sink available in the NOTIFIERS list
+func registerNotifier(name string, factory NotifierFactory) {
+	notifierFactories[name] = factory
+}
+
+type notifierSink struct {
+	notifier Notifier
+	queue    chan Notification
+	limiter  *RateLimiter
+}
+
+// NotifierRegistry sends every alert to the enabled sinks, each sink has its own queue and worker so a slow or
+// failing sink does not delay the others
+type NotifierRegistry struct {
+	sinks []*notifierSink
+	stage *Stage
+}
+
+var notifiers *NotifierRegistry
+
+func NewNotifierRegistry(names []string) *NotifierRegistry {
+	registry := &NotifierRegistry{stage: NewStage()}
+
+	for _, name := range names {
+		factory, ok := notifierFactories[name]
+		if !ok {
+			log.Printf("unknown notifier %s\n", name)
+			continue
+		}
+
+		notifier, err := factory()
+		if err != nil {
+			log.Printf("cannot init notifier %s, err: %s\n", name, err)
+			continue
+		}
+
+		sink := &notifierSink{notifier: notifier, queue: make(chan Notification, notifierQueueSize)}
+		if notifier.RateLimit() > 0 {
+			sink.limiter = NewRateLimiter(notifier.RateLimit(), 1)
+		}
+		registry.sinks = append(registry.sinks, sink)
+
+		registry.stage.wg.Add(1)
+		go registry.worker(sink)
+		log.Printf("Notifier %s enabled\n", name)
+	}
+
+	return registry
+}
+
+func (r *NotifierRegistry) worker(sink *notifierSink) {
+	defer r.stage.wg.Done()
+
+	consume(r.stage, sink.queue, func(notification Notification) {
+		notifierQueueMetric.WithLabelValues(sink.notifier.Name()).Dec()
+		r.deliver(sink, notification)
+	})
+}
+
+func (r *NotifierRegistry) deliver(sink *notifierSink, notification Notification) {
+	name := sink.notifier.Name()
+
+	if sink.limiter != nil {
+		sink.limiter.wait()
+	}
+
+	ctx, cancel := context.WithTimeout(drainCtx, notifierSendTimeout)
+	defer cancel()
+
+	var err error
+	if rich, ok := sink.notifier.(RichNotifier); ok {
+		err = rich.SendNotification(ctx, notification)
+	} else {
+		err = sink.notifier.Send(ctx, shortenText(notification.format(sink.notifier.Format()), sink.notifier.MaxLength()))
+	}
+	if err != nil {
+		notifierErrorsMetric.WithLabelValues(name).Inc()
+		log.Printf("Error sending alert to %s, err: %s\n", name, err)
+		return
+	}
+
+	notifierSentMetric.WithLabelValues(name).Inc()
+}
+
+// truncateText cuts the text to maxLength bytes without splitting a character, 0 means no limit
+func truncateText(text string, maxLength int) string {
+	if maxLength <= 0 || len(text) <= maxLength {
+		return text
+	}
+
+	return strings.ToValidUTF8(text[0:maxLength], "")
+}
+
+// shortenText cuts the text to maxLength bytes like truncateText, but a link is never cut: the text before it is
+// shortened so it fits, and the rest of the text is dropped
+func shortenText(text string, maxLength int) string {
+	if maxLength <= 0 || len(text) <= maxLength {
+		return text
+	}
+
+	cut := func(text string, maxLength int) string {
+		if maxLength <= 0 {
+			return ""
+		}
+		return truncateText(text, maxLength)
+	}
+
+	var builder strings.Builder
+	last := 0
+	for _, match := range urlRegexp.FindAllStringIndex(text, -1) {
+		before, link := text[last:match[0]], text[match[0]:match[1]]
+
+		remaining := maxLength - builder.Len()
+		if len(before)+len(link) > remaining {
+			if len(link) > remaining {
+				return builder.String() + cut(before, remaining)
+			}
+			return builder.String() + cut(before, remaining-len(link)) + link
+		}
+
+		builder.WriteString(before)
+		builder.WriteString(link)
+		last = match[1]
+	}
+
+	return builder.String() + cut(text[last:], maxLength-builder.Len())
+}
+
+// publish queues the alert for every sink, a sink whose queue is full misses the alert
+func (r *NotifierRegistry) publish(notification Notification) {
+	for _, sink := range r.sinks {
+		r.enqueue(sink, notification)
+	}
+}
+
+func (r *NotifierRegistry) enqueue(sink *notifierSink, notification Notification) {
+	select {
+	case sink.queue <- notification:
+		notifierQueueMetric.WithLabelValues(sink.notifier.Name()).Inc()
+	default:
+		notifierDroppedMetric.WithLabelValues(sink.notifier.Name()).Inc()
+		log.Printf("Notifier %s queue full, dropping alert\n", sink.notifier.Name())
+	}
+}
+
+// publishTo queues an already formatted alert for one sink
+func (r *NotifierRegistry) publishTo(name string, text string) error {
+	for _, sink := range r.sinks {
+		if sink.notifier.Name() == name {
+			r.enqueue(sink, Notification{html: text, text: text})
+			return nil
+		}
+	}
+
+	return fmt.Errorf("notifier %s is not enabled", name)
+}
+
+// render returns the text saved for an undelivered alert
+func (sink *notifierSink) render(notification Notification) string {
+	if renderer, ok := sink.notifier.(RenderingNotifier); ok {
+		return renderer.Render(notification)
+	}
+
+	return notification.format(sink.notifier.Format())
+}
+
+// refresh lets the sinks update the alerts they already sent
+func (r *NotifierRegistry) refresh() {
+	for _, sink := range r.sinks {
+		if refresher, ok := sink.notifier.(RefreshingNotifier); ok {
+			ctx, cancel := context.WithTimeout(drainCtx, notifierRefreshTimeout)
+			refresher.Refresh(ctx)
+			cancel()
+		}
+	}
+}
+
+// close stops the workers once the queues are drained or the drain deadline is over
+func (r *NotifierRegistry) close() {
+	r.stage.close()
+}
+
+// undelivered empties the queues of the sinks and returns the alerts formatted for each of them
+func (r *NotifierRegistry) undelivered(notifications []Notification) []UndeliveredAlert {
+	var alerts []UndeliveredAlert
+	for _, sink := range r.sinks {
+		for drained := false; !drained; {
+			select {
+			case notification := <-sink.queue:
+				notifierQueueMetric.WithLabelValues(sink.notifier.Name()).Dec()
+				alerts = append(alerts, UndeliveredAlert{Sink: sink.notifier.Name(), Text: sink.render(notification)})
+			default:
+				drained = true
+			}
+		}
+
+		for _, notification := range notifications {
+			alerts = append(alerts, UndeliveredAlert{Sink: sink.notifier.Name(), Text: sink.render(notification)})
+		}
+	}
+
+	return alerts
+}
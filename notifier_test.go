@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShortenText(t *testing.T) {
+	link := "https://explorer.alephium.org/#/transactions/abc"
+	body := "🐋 1M $ALPH transferred\nfrom to"
+
+	tests := []struct {
+		name      string
+		text      string
+		maxLength int
+		want      string
+	}{
+		{"fits", body + "\n\n" + link, 500, body + "\n\n" + link},
+		{"no limit", body + "\n\n" + link, 0, body + "\n\n" + link},
+		{"body shortened before the link", body + "\n\n" + link, 10 + len(link), "🐋 1M $A" + link},
+		{"link too long", body + "\n\n" + link, 10, "🐋 1M $A"},
+		{"text after the link dropped", link + " " + strings.Repeat("a", 20), len(link) + 5, link + " aaaa"},
+		{"no link", strings.Repeat("é", 10), 5, "éé"},
+	}
+
+	for _, test := range tests {
+		got := shortenText(test.text, test.maxLength)
+		if got != test.want {
+			t.Errorf("%s: shortenText() = %q, want %q", test.name, got, test.want)
+		}
+		if test.maxLength > 0 && len(got) > test.maxLength {
+			t.Errorf("%s: shortenText() is %d bytes, more than %d", test.name, len(got), test.maxLength)
+		}
+	}
+}
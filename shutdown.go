@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// time given to the queues to drain on shutdown, what is left in the notification queues is saved
+const shutdownTimeout = 30 * time.Second
+
+// drainCtx is done once the drain deadline is over, workers leave even if their queue is not empty
+var drainCtx, cancelDrain = context.WithCancel(context.Background())
+
+// startDrain starts the drain deadline
+func startDrain() {
+	time.AfterFunc(shutdownTimeout, cancelDrain)
+}
+
+// Stage is a group of workers consuming a queue, they leave once the stage is stopped and their queue is
+// empty, or when the drain deadline is over
+type Stage struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewStage() *Stage {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Stage{ctx: ctx, cancel: cancel}
+}
+
+// close stops the stage and waits for its workers to leave
+func (s *Stage) close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// consume handles the items of the queue, blocking while it is empty. Once the stage is stopped, the items
+// left are handled until the queue is empty or the drain deadline is over
+func consume[T any](stage *Stage, queue chan T, handle func(T)) {
+	for {
+		select {
+		case item := <-queue:
+			handle(item)
+		case <-stage.ctx.Done():
+			for drainCtx.Err() == nil {
+				select {
+				case item := <-queue:
+					handle(item)
+				default:
+					return
+				}
+			}
+			return
+		}
+	}
+}
+
+// sleepCtx waits for the duration, returns false if the context is done before
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// UndeliveredAlert is an alert formatted for a sink, saved on shutdown to be sent at the next start
+type UndeliveredAlert struct {
+	Sink string `json:"sink"`
+	Text string `json:"text"`
+}
+
+// saveUndelivered saves the alerts left in the notification queues and in the queues of the sinks, returns
+// their number. Alerts of blocks which were not confirmed yet are not saved, the blocks are replayed from the
+// cursor
+func saveUndelivered(path string, chMessages chan Message, chMessagesCex chan MessageCex) (int, error) {
+	alerts, err := loadUndelivered(path)
+	if err != nil {
+		log.Printf("cannot load previous undelivered alerts, err: %s\n", err)
+	}
+	previous := len(alerts)
+
+	var notifications []Notification
+	for drained := false; !drained; {
+		select {
+		case msg := <-chMessages:
+			notifications = append(notifications, newNotification(msg))
+			notificationQueueMetric.Dec()
+		case msg := <-chMessagesCex:
+			notifications = append(notifications, newCexNotification(msg))
+			cexQueueMetrics.Dec()
+		default:
+			drained = true
+		}
+	}
+	alerts = append(alerts, notifiers.undelivered(notifications)...)
+
+	if len(alerts) == previous {
+		return 0, nil
+	}
+
+	return len(alerts) - previous, writeJsonFile(path, alerts)
+}
+
+func loadUndelivered(path string) ([]UndeliveredAlert, error) {
+	dataBytes, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read undelivered file: %w", err)
+	}
+
+	var alerts []UndeliveredAlert
+	err = json.Unmarshal(dataBytes, &alerts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse undelivered file: %w", err)
+	}
+
+	return alerts, nil
+}
+
+// publishUndelivered queues the alerts saved at the last shutdown for their sink
+func publishUndelivered(path string) {
+	alerts, err := loadUndelivered(path)
+	if err != nil {
+		log.Printf("cannot load undelivered alerts, err: %s\n", err)
+		return
+	}
+
+	if len(alerts) == 0 {
+		return
+	}
+
+	log.Printf("Sending %d alerts undelivered at last shutdown\n", len(alerts))
+	for _, alert := range alerts {
+		if err := notifiers.publishTo(alert.Sink, alert.Text); err != nil {
+			log.Printf("Dropping undelivered alert, err: %s\n", err)
+		}
+	}
+
+	if err := os.Remove(path); err != nil {
+		log.Printf("cannot remove undelivered file, err: %s\n", err)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	// incoming webhooks allow about one message per second
+	slackRateLimit = 1.0
+	// max length of the text of a section block
+	slackMaxLength = 3000
+)
+
+// links of the HTML alerts, the only markup of messageFormat
+var htmlLinkRegexp = regexp.MustCompile(`<a href='([^']*)'>([^<]*)</a>`)
+
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type SlackBlock struct {
+	Type string     `json:"type"`
+	Text *SlackText `json:"text,omitempty"`
+}
+
+type SlackMessage struct {
+	// shown in notifications, the blocks are shown in the channel
+	Text   string       `json:"text"`
+	Blocks []SlackBlock `json:"blocks"`
+}
+
+type SlackNotifier struct {
+	targets []FilteredTarget
+	client  *retryablehttp.Client
+}
+
+func init() {
+	registerNotifier("slack", newSlackNotifier)
+}
+
+func newSlackNotifier() (Notifier, error) {
+	targets := loadFilteredTargets("SLACK_WEBHOOKS")
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("SLACK_WEBHOOKS is not set")
+	}
+
+	return &SlackNotifier{targets: targets, client: newHttpClient()}, nil
+}
+
+func (s *SlackNotifier) Name() string {
+	return "slack"
+}
+
+func (s *SlackNotifier) Format() Format {
+	return formatHtml
+}
+
+func (s *SlackNotifier) MaxLength() int {
+	return slackMaxLength
+}
+
+func (s *SlackNotifier) RateLimit() float64 {
+	return slackRateLimit
+}
+
+// Send posts an HTML alert restored from the undelivered file to every webhook
+func (s *SlackNotifier) Send(ctx context.Context, text string) error {
+	return s.SendNotification(ctx, Notification{html: text, text: text})
+}
+
+// SendNotification posts the alert to the webhooks whose filter matches
+func (s *SlackNotifier) SendNotification(ctx context.Context, notification Notification) error {
+	text := slackMarkdown(notification.html, slackMaxLength)
+	body, err := json.Marshal(SlackMessage{
+		Text:   htmlLinkRegexp.ReplaceAllString(notification.html, "$2"),
+		Blocks: []SlackBlock{{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: text}}},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot marshal slack message: %w", err)
+	}
+
+	var errs []error
+	for _, target := range s.targets {
+		if !target.filter.match(notification) {
+			continue
+		}
+
+		if err := s.post(ctx, target.value, body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *SlackNotifier) post(ctx context.Context, url string, body []byte) error {
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return newHttpError("https://hooks.slack.com", 0, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return newHttpError("https://hooks.slack.com", resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, respBody))
+	}
+
+	return nil
+}
+
+// slackMarkdown converts the HTML alert to mrkdwn of at most maxLength bytes, links become <url|text> and the
+// other control characters are escaped. A link is never cut: the text before it is shortened so it fits, and the
+// rest of the alert is dropped
+func slackMarkdown(html string, maxLength int) string {
+	var builder strings.Builder
+	last := 0
+	for _, match := range htmlLinkRegexp.FindAllStringSubmatchIndex(html, -1) {
+		text := html[last:match[0]]
+		link := fmt.Sprintf("<%s|%s>", html[match[2]:match[3]], slackEscape(html[match[4]:match[5]]))
+
+		remaining := maxLength - builder.Len()
+		if len(slackEscape(text))+len(link) > remaining {
+			if len(link) > remaining {
+				return builder.String() + slackEscapeTruncate(text, remaining)
+			}
+			return builder.String() + slackEscapeTruncate(text, remaining-len(link)) + link
+		}
+
+		builder.WriteString(slackEscape(text))
+		builder.WriteString(link)
+		last = match[1]
+	}
+
+	return builder.String() + slackEscapeTruncate(html[last:], maxLength-builder.Len())
+}
+
+// slackEscapeTruncate escapes the longest prefix of the text fitting in maxLength bytes, without cutting a
+// character or an escaped one
+func slackEscapeTruncate(text string, maxLength int) string {
+	var builder strings.Builder
+	for _, r := range text {
+		escaped := slackEscape(string(r))
+		if builder.Len()+len(escaped) > maxLength {
+			break
+		}
+		builder.WriteString(escaped)
+	}
+
+	return builder.String()
+}
+
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
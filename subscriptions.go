@@ -0,0 +1,588 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mymmrac/telego"
+)
+
+const (
+	maxSubscriptionsPerChat = 50
+	// ALPH moved with a token transfer to pay gas or the output deposit, not worth an alert on its own
+	subscriptionDustAlph   = 1.0
+	privateAlertsQueueSize = 100
+	// Telegram allows about 30 messages per second to different chats
+	privateAlertsRateLimit = 20.0
+)
+
+// ChatSubscriptions are the addresses and tokens watched by a user, tokens map the token id, empty for ALPH,
+// to the min amount
+type ChatSubscriptions struct {
+	Addresses []string           `json:"addresses"`
+	Tokens    map[string]float64 `json:"tokens"`
+}
+
+// Subscriptions are the personal watchlists of the Telegram users, indexed by address and token to match the
+// transactions in constant time
+type Subscriptions struct {
+	mu        sync.RWMutex
+	path      string
+	chats     map[int64]*ChatSubscriptions
+	byAddress map[string]map[int64]bool
+	byToken   map[string]map[int64]float64
+}
+
+func NewSubscriptions() *Subscriptions {
+	return &Subscriptions{
+		chats:     make(map[int64]*ChatSubscriptions),
+		byAddress: make(map[string]map[int64]bool),
+		byToken:   make(map[string]map[int64]float64),
+	}
+}
+
+var subscriptions = NewSubscriptions()
+
+// load reads the subscriptions saved at path, later changes are saved there
+func (s *Subscriptions) load(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.path = path
+	dataBytes, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read subscriptions file: %w", err)
+	}
+
+	var chats map[int64]*ChatSubscriptions
+	if err := json.Unmarshal(dataBytes, &chats); err != nil {
+		return fmt.Errorf("cannot parse subscriptions file: %w", err)
+	}
+
+	for chatId, chat := range chats {
+		if chat.Tokens == nil {
+			chat.Tokens = make(map[string]float64)
+		}
+		s.chats[chatId] = chat
+		for _, address := range chat.Addresses {
+			s.indexAddress(chatId, address)
+		}
+		for tokenId, minAmount := range chat.Tokens {
+			s.indexToken(chatId, tokenId, minAmount)
+		}
+	}
+	s.updateMetric()
+
+	return nil
+}
+
+func (s *Subscriptions) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	return writeJsonFile(s.path, s.chats)
+}
+
+func (s *Subscriptions) chat(chatId int64) *ChatSubscriptions {
+	chat, ok := s.chats[chatId]
+	if !ok {
+		chat = &ChatSubscriptions{Tokens: make(map[string]float64)}
+		s.chats[chatId] = chat
+	}
+
+	return chat
+}
+
+func (chat *ChatSubscriptions) count() int {
+	return len(chat.Addresses) + len(chat.Tokens)
+}
+
+func (s *Subscriptions) indexAddress(chatId int64, address string) {
+	if s.byAddress[address] == nil {
+		s.byAddress[address] = make(map[int64]bool)
+	}
+	s.byAddress[address][chatId] = true
+}
+
+func (s *Subscriptions) indexToken(chatId int64, tokenId string, minAmount float64) {
+	if s.byToken[tokenId] == nil {
+		s.byToken[tokenId] = make(map[int64]float64)
+	}
+	s.byToken[tokenId][chatId] = minAmount
+}
+
+func (s *Subscriptions) updateMetric() {
+	count := 0
+	for _, chat := range s.chats {
+		count += chat.count()
+	}
+	subscriptionsMetric.Set(float64(count))
+}
+
+// watchAddress subscribes the chat to the transactions of the address, the change is undone if it cannot be saved
+func (s *Subscriptions) watchAddress(chatId int64, address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.byAddress[address][chatId] {
+		return nil
+	}
+	if chat, ok := s.chats[chatId]; ok && chat.count() >= maxSubscriptionsPerChat {
+		return fmt.Errorf("you cannot watch more than %d addresses and tokens", maxSubscriptionsPerChat)
+	}
+
+	s.addAddress(chatId, address)
+	if err := s.save(); err != nil {
+		s.removeAddress(chatId, address)
+		return err
+	}
+	s.updateMetric()
+
+	return nil
+}
+
+func (s *Subscriptions) unwatchAddress(chatId int64, address string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.byAddress[address][chatId] {
+		return false, nil
+	}
+
+	s.removeAddress(chatId, address)
+	if err := s.save(); err != nil {
+		s.addAddress(chatId, address)
+		return false, err
+	}
+	s.updateMetric()
+
+	return true, nil
+}
+
+// watchToken subscribes the chat to the transfers of the token of at least minAmount, the change is undone if
+// it cannot be saved
+func (s *Subscriptions) watchToken(chatId int64, tokenId string, minAmount float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var previous float64
+	var watched bool
+	if chat, ok := s.chats[chatId]; ok {
+		previous, watched = chat.Tokens[tokenId]
+		if !watched && chat.count() >= maxSubscriptionsPerChat {
+			return fmt.Errorf("you cannot watch more than %d addresses and tokens", maxSubscriptionsPerChat)
+		}
+	}
+
+	s.setToken(chatId, tokenId, minAmount)
+	if err := s.save(); err != nil {
+		if watched {
+			s.setToken(chatId, tokenId, previous)
+		} else {
+			s.removeToken(chatId, tokenId)
+		}
+		return err
+	}
+	s.updateMetric()
+
+	return nil
+}
+
+func (s *Subscriptions) unwatchToken(chatId int64, tokenId string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat, ok := s.chats[chatId]
+	if !ok {
+		return false, nil
+	}
+	minAmount, ok := chat.Tokens[tokenId]
+	if !ok {
+		return false, nil
+	}
+
+	s.removeToken(chatId, tokenId)
+	if err := s.save(); err != nil {
+		s.setToken(chatId, tokenId, minAmount)
+		return false, err
+	}
+	s.updateMetric()
+
+	return true, nil
+}
+
+func (s *Subscriptions) addAddress(chatId int64, address string) {
+	chat := s.chat(chatId)
+	chat.Addresses = append(chat.Addresses, address)
+	s.indexAddress(chatId, address)
+}
+
+func (s *Subscriptions) removeAddress(chatId int64, address string) {
+	chat := s.chats[chatId]
+	for i, a := range chat.Addresses {
+		if a == address {
+			chat.Addresses = append(chat.Addresses[:i], chat.Addresses[i+1:]...)
+			break
+		}
+	}
+	delete(s.byAddress[address], chatId)
+	if len(s.byAddress[address]) == 0 {
+		delete(s.byAddress, address)
+	}
+	s.removeEmpty(chatId)
+}
+
+func (s *Subscriptions) setToken(chatId int64, tokenId string, minAmount float64) {
+	s.chat(chatId).Tokens[tokenId] = minAmount
+	s.indexToken(chatId, tokenId, minAmount)
+}
+
+func (s *Subscriptions) removeToken(chatId int64, tokenId string) {
+	delete(s.chats[chatId].Tokens, tokenId)
+	delete(s.byToken[tokenId], chatId)
+	if len(s.byToken[tokenId]) == 0 {
+		delete(s.byToken, tokenId)
+	}
+	s.removeEmpty(chatId)
+}
+
+func (s *Subscriptions) removeEmpty(chatId int64) {
+	if chat, ok := s.chats[chatId]; ok && chat.count() == 0 {
+		delete(s.chats, chatId)
+	}
+}
+
+// list returns a copy of the subscriptions of the chat
+func (s *Subscriptions) list(chatId int64) ChatSubscriptions {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chat, ok := s.chats[chatId]
+	if !ok {
+		return ChatSubscriptions{}
+	}
+
+	list := ChatSubscriptions{Addresses: append([]string(nil), chat.Addresses...), Tokens: make(map[string]float64)}
+	for tokenId, minAmount := range chat.Tokens {
+		list.Tokens[tokenId] = minAmount
+	}
+
+	return list
+}
+
+// match returns the private alerts of the flows of a transaction, at most one per chat and asset
+func (s *Subscriptions) match(flows []AssetFlow, txId Tx) []PrivateAlert {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.chats) == 0 {
+		return nil
+	}
+
+	var alerts []PrivateAlert
+	for _, flow := range flows {
+		if len(flow.senders) == 0 || len(flow.recipients) == 0 {
+			continue
+		}
+
+		// most flows match no watchlist, skip them before building the alert
+		watched := len(s.byToken[flow.tokenId]) > 0
+		for _, addresses := range [][]AddressAmount{flow.senders, flow.recipients} {
+			for _, address := range addresses {
+				watched = watched || len(s.byAddress[address.address]) > 0
+			}
+		}
+		if !watched {
+			continue
+		}
+
+		msg, amount := flowMessage(flow, txId)
+		chats := make(map[int64]string)
+
+		if flow.tokenId != alphFlowId || amount >= subscriptionDustAlph {
+			for _, addresses := range [][]AddressAmount{flow.senders, flow.recipients} {
+				for _, address := range addresses {
+					for chatId := range s.byAddress[address.address] {
+						chats[chatId] = "watched address " + address.address
+					}
+				}
+			}
+		}
+
+		for chatId, minAmount := range s.byToken[flow.tokenId] {
+			if _, ok := chats[chatId]; !ok && amount >= minAmount {
+				chats[chatId] = fmt.Sprintf("transfer above %s", Amount{Value: minAmount, Symbol: "$" + msg.symbol()}.formatHuman())
+			}
+		}
+
+		for chatId, reason := range chats {
+			alerts = append(alerts, PrivateAlert{chatId: chatId, reason: reason, msg: msg})
+		}
+	}
+
+	return alerts
+}
+
+// flowMessage builds the transfer alert of the flow and returns its amount with decimals applied
+func flowMessage(flow AssetFlow, txId Tx) (Message, float64) {
+	amountFloat, _ := new(big.Float).SetInt(flow.amount).Float64()
+	msg := Message{
+		from:          flow.senders[0].address,
+		to:            flow.recipients[0].address,
+		fromAddresses: addresses(flow.senders),
+		toAddresses:   addresses(flow.recipients),
+		txId:          txId.id,
+		groupFrom:     txId.groupFrom,
+		groupTo:       txId.groupTo,
+		blockHash:     txId.blockHash,
+		height:        txId.height,
+		status:        txId.status,
+	}
+
+	if flow.tokenId == alphFlowId {
+		msg.amountChain = amountFloat / baseAlph
+		return msg, msg.amountChain
+	}
+
+	msg.amountChain = amountFloat
+	msg.tokenData = searchTokenData(flow.tokenId)
+	if msg.tokenData.Name == "" {
+		// unknown tokens are shown by id without decimals
+		msg.tokenData = Token{ID: flow.tokenId, Name: flow.tokenId, Symbol: flow.tokenId[:min(8, len(flow.tokenId))]}
+	}
+
+	return msg, amountFloat / math.Pow(10.0, float64(msg.tokenData.Decimals))
+}
+
+func (msg Message) symbol() string {
+	_, symbol := msg.humanAmount()
+	return symbol
+}
+
+// tokenIdBySymbol resolves ALPH and the tokens of the token list
+func tokenIdBySymbol(symbol string) (string, string, bool) {
+	symbol = strings.TrimPrefix(symbol, "$")
+	if strings.EqualFold(symbol, "ALPH") {
+		return alphFlowId, "ALPH", true
+	}
+
+	for _, token := range Tokens.Tokens {
+		if strings.EqualFold(token.Symbol, symbol) {
+			return token.ID, token.Symbol, true
+		}
+	}
+
+	return "", "", false
+}
+
+type PrivateAlert struct {
+	chatId int64
+	reason string
+	msg    Message
+}
+
+// PrivateAlerts sends the alerts of the personal watchlists to the users, apart from the public sinks
+type PrivateAlerts struct {
+	bot     *telego.Bot
+	queue   chan PrivateAlert
+	limiter *RateLimiter
+	stage   *Stage
+}
+
+// privateAlerts is nil when the bot commands are disabled
+var privateAlerts *PrivateAlerts
+
+func NewPrivateAlerts(bot *telego.Bot) *PrivateAlerts {
+	p := &PrivateAlerts{
+		bot:     bot,
+		queue:   make(chan PrivateAlert, privateAlertsQueueSize),
+		limiter: NewRateLimiter(privateAlertsRateLimit, 1),
+		stage:   NewStage(),
+	}
+
+	p.stage.wg.Add(1)
+	go p.worker()
+
+	return p
+}
+
+// checkSubscriptions queues the private alerts of the transaction flows. Mempool transactions are skipped, the
+// subscribers are alerted once when the transaction is mined
+func checkSubscriptions(flows []AssetFlow, txId Tx) {
+	if privateAlerts == nil || txId.status == statusPending {
+		return
+	}
+
+	for _, alert := range subscriptions.match(flows, txId) {
+		privateAlerts.push(alert)
+	}
+}
+
+func (p *PrivateAlerts) push(alert PrivateAlert) {
+	select {
+	case p.queue <- alert:
+	default:
+		privateAlertsMetric.WithLabelValues("dropped").Inc()
+		log.Printf("Private alerts queue full, dropping alert of tx %s\n", alert.msg.txId)
+	}
+}
+
+func (p *PrivateAlerts) worker() {
+	defer p.stage.wg.Done()
+
+	consume(p.stage, p.queue, func(alert PrivateAlert) {
+		p.limiter.wait()
+
+		text := fmt.Sprintf("🔔 Watchlist: %s\n\n%s", alert.reason, messageFormat(alert.msg, false))
+		_, err := p.bot.SendMessage(&telego.SendMessageParams{
+			ChatID:             telego.ChatID{ID: alert.chatId},
+			Text:               text,
+			LinkPreviewOptions: &telego.LinkPreviewOptions{IsDisabled: true},
+		})
+		if err != nil {
+			privateAlertsMetric.WithLabelValues("error").Inc()
+			log.Printf("Error sending private alert to %d, err: %s\n", alert.chatId, err)
+			return
+		}
+
+		privateAlertsMetric.WithLabelValues("sent").Inc()
+	})
+}
+
+// close stops the worker once the queue is drained or the drain deadline is over
+func (p *PrivateAlerts) close() {
+	p.stage.close()
+}
+
+func watchCommand(chatId int64, args []string) string {
+	if len(args) == 0 {
+		return "Usage: /watch &lt;address&gt;"
+	}
+
+	address := args[0]
+	if !isValidAddress(address) {
+		return "Invalid address"
+	}
+
+	if err := subscriptions.watchAddress(chatId, address); err != nil {
+		log.Printf("cannot watch address for %d, err: %s\n", chatId, err)
+		return fmt.Sprintf("Cannot watch the address: %s", err)
+	}
+
+	return fmt.Sprintf("Watching %s, you will receive its transactions", address)
+}
+
+func unwatchCommand(chatId int64, args []string) string {
+	if len(args) == 0 {
+		return "Usage: /unwatch &lt;address&gt;"
+	}
+
+	removed, err := subscriptions.unwatchAddress(chatId, args[0])
+	if err != nil {
+		log.Printf("cannot unwatch address for %d, err: %s\n", chatId, err)
+		return "Cannot remove the address, try again later"
+	}
+	if !removed {
+		return "This address is not in your watchlist"
+	}
+
+	return "Address removed from your watchlist"
+}
+
+func tokenCommand(chatId int64, args []string) string {
+	if len(args) < 2 {
+		return "Usage: /token &lt;symbol&gt; &lt;min amount&gt;"
+	}
+
+	tokenId, symbol, ok := tokenIdBySymbol(args[0])
+	if !ok {
+		return "Unknown token"
+	}
+
+	minAmount, err := parseAmount(args[1])
+	if err != nil || minAmount <= 0 {
+		return "Invalid min amount, e.g. 1000 or 1.5k"
+	}
+
+	if err := subscriptions.watchToken(chatId, tokenId, minAmount); err != nil {
+		log.Printf("cannot watch token for %d, err: %s\n", chatId, err)
+		return fmt.Sprintf("Cannot watch the token: %s", err)
+	}
+
+	return fmt.Sprintf("Watching $%s transfers of at least %s", symbol, Amount{Value: minAmount, Symbol: "$" + symbol}.formatHuman())
+}
+
+func untokenCommand(chatId int64, args []string) string {
+	if len(args) == 0 {
+		return "Usage: /untoken &lt;symbol&gt;"
+	}
+
+	tokenId, _, ok := tokenIdBySymbol(args[0])
+	if !ok {
+		return "Unknown token"
+	}
+
+	removed, err := subscriptions.unwatchToken(chatId, tokenId)
+	if err != nil {
+		log.Printf("cannot unwatch token for %d, err: %s\n", chatId, err)
+		return "Cannot remove the token, try again later"
+	}
+	if !removed {
+		return "This token is not in your watchlist"
+	}
+
+	return "Token removed from your watchlist"
+}
+
+func watchlistCommand(chatId int64) string {
+	list := subscriptions.list(chatId)
+	if list.count() == 0 {
+		return "Your watchlist is empty, use /watch &lt;address&gt; or /token &lt;symbol&gt; &lt;min&gt;"
+	}
+
+	text := "Your watchlist:\n"
+	for _, address := range list.Addresses {
+		text += address + "\n"
+	}
+
+	var tokenLines []string
+	for tokenId, minAmount := range list.Tokens {
+		symbol := "ALPH"
+		if tokenId != alphFlowId {
+			symbol = searchTokenData(tokenId).Symbol
+		}
+		tokenLines = append(tokenLines, fmt.Sprintf("$%s from %s\n", symbol, Amount{Value: minAmount, Symbol: "$" + symbol}.formatHuman()))
+	}
+	sort.Strings(tokenLines)
+
+	return text + strings.Join(tokenLines, "")
+}
+
+// parseAmount parses an amount with an optional k or m suffix
+func parseAmount(value string) (float64, error) {
+	multiplier := 1.0
+	lower := strings.ToLower(value)
+	switch {
+	case strings.HasSuffix(lower, "k"):
+		multiplier = 1e3
+		lower = strings.TrimSuffix(lower, "k")
+	case strings.HasSuffix(lower, "m"):
+		multiplier = 1e6
+		lower = strings.TrimSuffix(lower, "m")
+	}
+
+	amount, err := strconv.ParseFloat(lower, 64)
+	return amount * multiplier, err
+}
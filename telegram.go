@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+	ta "github.com/mymmrac/telego/telegoapi"
+)
+
+// Telegram allows 20 messages per minute in a group
+const telegramRateLimit = 20.0 / 60.0
+
+// edits of the sent alerts have their own budget so they do not delay the new alerts
+const telegramEditRateLimit = 10.0 / 60.0
+
+// TelegramRoute is a chat, or a topic of a forum chat, with the alerts it receives
+type TelegramRoute struct {
+	chatId   int64
+	threadId int
+	filter   AlertFilter
+	// min USD value of the alerts, alerts without price are skipped when set
+	minUsd float64
+	// only alerts with a known exchange on one side, and CEX trades
+	exchangesOnly bool
+	// groups of the on-chain alerts, any group when empty
+	groups      map[int]bool
+	article     bool
+	limiter     *RateLimiter
+	editLimiter *RateLimiter
+}
+
+type TelegramNotifier struct {
+	bot    *telego.Bot
+	routes []TelegramRoute
+	sent   *SentTelegramAlerts
+	// serializes the edits of the status updates and of the refreshes
+	editMu sync.Mutex
+}
+
+func init() {
+	registerNotifier("telegram", newTelegramNotifier)
+}
+
+func newTelegramNotifier() (Notifier, error) {
+	if len(parameters.TelegramRoutes) == 0 {
+		return nil, fmt.Errorf("CHAT_ID and TELEGRAM_CHATS are not set")
+	}
+
+	bot, err := initTelegram()
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make([]TelegramRoute, len(parameters.TelegramRoutes))
+	for i, route := range parameters.TelegramRoutes {
+		route.limiter = NewRateLimiter(telegramRateLimit, 1)
+		route.editLimiter = NewRateLimiter(telegramEditRateLimit, 1)
+		routes[i] = route
+	}
+
+	return &TelegramNotifier{bot: bot, routes: routes, sent: NewSentTelegramAlerts()}, nil
+}
+
+// loadTelegramRoutes parses TELEGRAM_CHATS, chats separated by "," with options separated by ";", e.g.
+// "-1001;alerts=alph;min_usd=1000000,-1002/15;exchanges;article=off". A chat may be followed by "/topic". The
+// options are alerts (see parseAlertFilter), min_usd, exchanges, groups ("0+1") and article (on or off). Without
+// TELEGRAM_CHATS every alert goes to CHAT_ID
+func loadTelegramRoutes(defaultChatId int64) []TelegramRoute {
+	var routes []TelegramRoute
+	for _, item := range splitList(os.Getenv("TELEGRAM_CHATS")) {
+		route, err := parseTelegramRoute(item)
+		if err != nil {
+			log.Printf("cannot parse telegram chat %s, err: %s\n", item, err)
+			continue
+		}
+		routes = append(routes, route)
+	}
+
+	if len(routes) == 0 && defaultChatId != 0 {
+		routes = append(routes, TelegramRoute{chatId: defaultChatId, filter: allAlerts, article: true})
+	}
+
+	return routes
+}
+
+func parseTelegramRoute(item string) (TelegramRoute, error) {
+	options := strings.Split(item, ";")
+	route := TelegramRoute{filter: allAlerts, article: true}
+
+	chat, thread, hasThread := strings.Cut(strings.TrimSpace(options[0]), "/")
+	chatId, err := strconv.ParseInt(chat, 10, 64)
+	if err != nil {
+		return route, fmt.Errorf("invalid chat id: %w", err)
+	}
+	route.chatId = chatId
+	if hasThread {
+		if route.threadId, err = strconv.Atoi(thread); err != nil {
+			return route, fmt.Errorf("invalid topic id: %w", err)
+		}
+	}
+
+	for _, option := range options[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		switch strings.ToLower(key) {
+		case "alerts":
+			if route.filter, err = parseAlertFilter(value); err != nil {
+				return route, err
+			}
+		case "min_usd":
+			if route.minUsd, err = strconv.ParseFloat(value, 64); err != nil {
+				return route, fmt.Errorf("invalid min_usd: %w", err)
+			}
+		case "exchanges":
+			route.exchangesOnly = true
+		case "groups":
+			route.groups = make(map[int]bool)
+			for _, group := range strings.Split(value, "+") {
+				groupInt, err := strconv.Atoi(group)
+				if err != nil || groupInt < 0 || groupInt >= groupNum {
+					return route, fmt.Errorf("invalid group %s", group)
+				}
+				route.groups[groupInt] = true
+			}
+		case "article":
+			route.article = !strings.EqualFold(value, "off")
+		default:
+			return route, fmt.Errorf("unknown option %s", key)
+		}
+	}
+
+	return route, nil
+}
+
+// restricted returns true if the route selects alerts beyond their kind, alerts restored with their text only
+// cannot be checked and skip these routes
+func (route TelegramRoute) restricted() bool {
+	return route.filter != allAlerts || route.minUsd > 0 || route.exchangesOnly || len(route.groups) > 0
+}
+
+// match returns true if the alert passes the rules of the route
+func (route TelegramRoute) match(notification Notification) bool {
+	if !route.filter.match(notification) {
+		return false
+	}
+
+	if notification.cex != nil {
+		return notification.cex.AmountFiat.Value >= route.minUsd
+	}
+
+	msg := notification.msg
+	if msg == nil {
+		return !route.restricted()
+	}
+
+	if route.minUsd > 0 {
+		usd, ok := msg.usdValue()
+		if !ok || usd < route.minUsd {
+			return false
+		}
+	}
+
+	if route.exchangesOnly && !msg.involvesExchange() {
+		return false
+	}
+
+	if len(route.groups) > 0 && !route.groups[msg.groupFrom] && !route.groups[msg.groupTo] {
+		return false
+	}
+
+	return true
+}
+
+// involvesExchange returns true if an address of the alert is a known exchange wallet
+func (msg Message) involvesExchange() bool {
+	for _, addresses := range [][]string{{msg.from, msg.to}, msg.fromAddresses, msg.toAddresses} {
+		for _, address := range addresses {
+			if getAddressName(&address).ExchangeName != "" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func initTelegram() (*telego.Bot, error) {
+	return telego.NewBot(parameters.TelegramTokenApi, telego.WithAPICaller(&ta.RetryCaller{
+		// Use caller
+		Caller: ta.DefaultFastHTTPCaller,
+		// Max number of attempts to make call
+		MaxAttempts: 15,
+		// Exponent base for delay
+		ExponentBase: 2,
+		// Starting delay duration
+		StartDelay: time.Millisecond * 45,
+		// Maximum delay duration
+		MaxDelay: time.Second * 120,
+	}))
+}
+
+func (t *TelegramNotifier) Name() string {
+	return "telegram"
+}
+
+func (t *TelegramNotifier) Format() Format {
+	return formatHtml
+}
+
+func (t *TelegramNotifier) MaxLength() int {
+	return 4096
+}
+
+// RateLimit is applied per chat by the routes
+func (t *TelegramNotifier) RateLimit() float64 {
+	return 0
+}
+
+// Send posts an alert restored with its text only to the unrestricted chats
+func (t *TelegramNotifier) Send(ctx context.Context, text string) error {
+	return t.SendNotification(ctx, Notification{html: text, text: text})
+}
+
+// SendNotification posts the alert to the chats whose rules match, status updates of transfer alerts sent by
+// this process edit them instead. The telegram client has no context support so it is only checked before each
+// message
+func (t *TelegramNotifier) SendNotification(ctx context.Context, notification Notification) error {
+	if notification.msg != nil && notification.msg.followUp && t.editStatus(ctx, *notification.msg) {
+		return nil
+	}
+
+	// transfer alerts are kept to be edited
+	var alert *SentTelegramAlert
+	if isTransferAlert(notification) {
+		alert = newSentTelegramAlert(*notification.msg)
+	}
+
+	var errs []error
+	for i, route := range t.routes {
+		if !route.match(notification) {
+			continue
+		}
+
+		text := notification.html
+		var article string
+		if alert != nil {
+			if route.article {
+				article = featuredArticle()
+			}
+			text = alert.render(article)
+		}
+		text = truncateText(text, t.MaxLength())
+
+		route.limiter.wait()
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		messageId, err := sendTelegramMessage(t.bot, route.chatId, route.threadId, text)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("chat %d: %w", route.chatId, err))
+			continue
+		}
+
+		if alert != nil {
+			alert.messages = append(alert.messages, sentTelegramMessage{route: i, messageId: messageId, article: article, text: text})
+		}
+	}
+
+	if alert != nil && len(alert.messages) > 0 {
+		t.sent.add(alert)
+	}
+
+	return errors.Join(errs...)
+}
+
+// isTransferAlert returns true for the alerts of plain transfers, the only ones with a featured article
+func isTransferAlert(notification Notification) bool {
+	msg := notification.msg
+	return msg != nil && !msg.followUp && msg.swap == nil && msg.event == nil && msg.bridge == nil
+}
+
+// sendTelegramMessage posts the message and returns its id
+func sendTelegramMessage(b *telego.Bot, chatId int64, threadId int, message string) (int, error) {
+	chatID := telego.ChatID{ID: chatId}
+	sent, err := b.SendMessage(&telego.SendMessageParams{
+		ChatID:             chatID,
+		MessageThreadID:    threadId,
+		Text:               message,
+		LinkPreviewOptions: &telego.LinkPreviewOptions{IsDisabled: true},
+		ParseMode:          telego.ModeHTML,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error telegram bot: %w", err)
+	}
+
+	return sent.MessageID, nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+)
+
+const (
+	// sent alerts are edited during that time
+	telegramEditWindow    = 24 * time.Hour
+	telegramMaxSentAlerts = 1000
+	// the USD value is refreshed when the ALPH price moved at least that much since the alert was rendered
+	telegramUsdRefreshRatio = 0.05
+	// alerts edited by a refresh, newest first, to keep it within the edit budget
+	telegramMaxRefreshEdits = 20
+)
+
+type sentTelegramMessage struct {
+	route     int
+	messageId int
+	article   string
+	// last text sent, edits are skipped when the text did not change
+	text string
+}
+
+// SentTelegramAlert is a transfer alert sent to the chats, edited when its status, the known wallets or the
+// price change
+type SentTelegramAlert struct {
+	msg      Message
+	sentAt   time.Time
+	messages []sentTelegramMessage
+	// the block status changed after the alert was sent
+	statusUpdated bool
+	// the receiver was a known exchange when the alert was sent
+	exchangeKnown bool
+	// exchange of the receiver found by a later refresh of the known wallets
+	exchange       string
+	alphPrice      float64
+	usdRefreshedAt time.Time
+}
+
+// SentTelegramAlerts keeps the recent alerts by transaction and asset
+type SentTelegramAlerts struct {
+	mu     sync.Mutex
+	alerts map[string]*SentTelegramAlert
+	keys   []string
+}
+
+func NewSentTelegramAlerts() *SentTelegramAlerts {
+	return &SentTelegramAlerts{alerts: make(map[string]*SentTelegramAlert)}
+}
+
+func sentAlertKey(msg Message) string {
+	tokenId, _, _ := msg.asset()
+	return msg.txId + "/" + tokenId
+}
+
+func (s *SentTelegramAlerts) add(alert *SentTelegramAlert) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := sentAlertKey(alert.msg)
+	if _, ok := s.alerts[key]; !ok {
+		s.keys = append(s.keys, key)
+	}
+	s.alerts[key] = alert
+
+	// oldest first
+	cutoff := time.Now().Add(-telegramEditWindow)
+	for len(s.keys) > 0 {
+		oldest, ok := s.alerts[s.keys[0]]
+		if ok && oldest.sentAt.After(cutoff) && len(s.keys) <= telegramMaxSentAlerts {
+			break
+		}
+		delete(s.alerts, s.keys[0])
+		s.keys = s.keys[1:]
+	}
+}
+
+func (s *SentTelegramAlerts) get(msg Message) (*SentTelegramAlert, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alert, ok := s.alerts[sentAlertKey(msg)]
+	if !ok || time.Since(alert.sentAt) > telegramEditWindow {
+		return nil, false
+	}
+
+	return alert, true
+}
+
+func (s *SentTelegramAlerts) recent() []*SentTelegramAlert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var alerts []*SentTelegramAlert
+	for _, key := range s.keys {
+		if alert := s.alerts[key]; time.Since(alert.sentAt) <= telegramEditWindow {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	return alerts
+}
+
+func newSentTelegramAlert(msg Message) *SentTelegramAlert {
+	return &SentTelegramAlert{
+		msg:           msg,
+		sentAt:        time.Now(),
+		exchangeKnown: receivingExchange(msg) != "",
+		alphPrice:     coinGeckoPrice,
+	}
+}
+
+// render formats the alert with its updates, the article is the one of the chat
+func (alert *SentTelegramAlert) render(article string) string {
+	var text string
+	if alert.statusUpdated {
+		switch alert.msg.status {
+		case statusConfirmed:
+			text = "✅ Confirmed\n"
+		case statusOrphaned:
+			text = "❌ Orphaned, block dropped out of the main chain\n"
+		}
+	}
+
+	msg := alert.msg
+	msg.followUp = false
+	text += messageFormat(msg, true)
+
+	if alert.exchange != "" {
+		text += fmt.Sprintf("🏦 Exchange deposit detected: %s\n", alert.exchange)
+	}
+	if !alert.usdRefreshedAt.IsZero() {
+		text += fmt.Sprintf("💵 USD value refreshed at %s UTC\n", alert.usdRefreshedAt.UTC().Format("15:04"))
+	}
+
+	return text + article
+}
+
+// receivingExchange returns the exchange of a receiver of the alert, empty if none is an exchange
+func receivingExchange(msg Message) string {
+	for _, address := range append([]string{msg.to}, msg.toAddresses...) {
+		if name := getAddressName(&address).ExchangeName; name != "" {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// editStatus updates the alert of a status update in place, false if the alert was not sent by this process or
+// none of its messages could be edited, the update is then sent as a new message
+func (t *TelegramNotifier) editStatus(ctx context.Context, msg Message) bool {
+	alert, ok := t.sent.get(msg)
+	if !ok {
+		return false
+	}
+
+	t.editMu.Lock()
+	defer t.editMu.Unlock()
+
+	alert.msg.status = msg.status
+	alert.statusUpdated = true
+
+	return t.edit(ctx, alert) > 0
+}
+
+// Refresh tags the alerts whose receiver became a known exchange and refreshes the USD value of the ALPH
+// alerts after a price move, the newest alerts first and at most telegramMaxRefreshEdits of them
+func (t *TelegramNotifier) Refresh(ctx context.Context) {
+	alerts := t.sent.recent()
+	edited := 0
+	for i := len(alerts) - 1; i >= 0 && edited < telegramMaxRefreshEdits; i-- {
+		if ctx.Err() != nil {
+			return
+		}
+
+		alert := alerts[i]
+
+		t.editMu.Lock()
+		changed := false
+		if !alert.exchangeKnown && alert.exchange == "" {
+			if name := receivingExchange(alert.msg); name != "" {
+				alert.exchange = name
+				changed = true
+			}
+		}
+
+		if alert.msg.tokenData.Name == "" && alert.alphPrice > 0 && coinGeckoPrice > 0 &&
+			math.Abs(coinGeckoPrice/alert.alphPrice-1) >= telegramUsdRefreshRatio {
+			alert.alphPrice = coinGeckoPrice
+			alert.usdRefreshedAt = time.Now()
+			changed = true
+		}
+
+		if changed {
+			t.edit(ctx, alert)
+			edited++
+		}
+		t.editMu.Unlock()
+	}
+}
+
+// edit sends the new text of the alert to the chats where it changed and returns the number of messages up to
+// date, must be called with editMu held
+func (t *TelegramNotifier) edit(ctx context.Context, alert *SentTelegramAlert) int {
+	upToDate := 0
+	for i := range alert.messages {
+		message := &alert.messages[i]
+		text := truncateText(alert.render(message.article), t.MaxLength())
+		if text == message.text {
+			upToDate++
+			continue
+		}
+
+		route := t.routes[message.route]
+		route.editLimiter.wait()
+		if ctx.Err() != nil {
+			return upToDate
+		}
+
+		if err := editTelegramMessage(t.bot, route.chatId, message.messageId, text); err != nil {
+			telegramEditsMetric.WithLabelValues("error").Inc()
+			log.Printf("Error editing alert of tx %s in chat %d, err: %s\n", alert.msg.txId, route.chatId, err)
+			continue
+		}
+
+		telegramEditsMetric.WithLabelValues("edited").Inc()
+		message.text = text
+		upToDate++
+	}
+
+	return upToDate
+}
+
+func editTelegramMessage(b *telego.Bot, chatId int64, messageId int, text string) error {
+	_, err := b.EditMessageText(&telego.EditMessageTextParams{
+		ChatID:             telego.ChatID{ID: chatId},
+		MessageID:          messageId,
+		Text:               text,
+		LinkPreviewOptions: &telego.LinkPreviewOptions{IsDisabled: true},
+		ParseMode:          telego.ModeHTML,
+	})
+	if err != nil {
+		return fmt.Errorf("error telegram bot: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/michimani/gotwi"
+	"github.com/michimani/gotwi/tweet/managetweet"
+	"github.com/michimani/gotwi/tweet/managetweet/types"
+)
+
+// Twitter allows 100 posts per 15 minutes for a user
+const twitterRateLimit = 100.0 / (15 * 60)
+
+type TwitterNotifier struct {
+	client *gotwi.Client
+}
+
+func init() {
+	registerNotifier("twitter", newTwitterNotifier)
+}
+
+func newTwitterNotifier() (Notifier, error) {
+	if parameters.TwitterAccessToken == "" || parameters.TwitterAccessTokenSecret == "" {
+		return nil, fmt.Errorf("TWITTER_ACCESS_TOKEN and TWITTER_ACCESS_SECRET are not set")
+	}
+
+	client, err := initTwitter()
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwitterNotifier{client: client}, nil
+}
+
+func initTwitter() (*gotwi.Client, error) {
+	in := &gotwi.NewClientInput{
+		AuthenticationMethod: gotwi.AuthenMethodOAuth1UserContext,
+		OAuthToken:           parameters.TwitterAccessToken,
+		OAuthTokenSecret:     parameters.TwitterAccessTokenSecret,
+	}
+
+	return gotwi.NewClient(in)
+}
+
+func (t *TwitterNotifier) Name() string {
+	return "twitter"
+}
+
+func (t *TwitterNotifier) Format() Format {
+	return formatText
+}
+
+func (t *TwitterNotifier) MaxLength() int {
+	return 280
+}
+
+func (t *TwitterNotifier) RateLimit() float64 {
+	return twitterRateLimit
+}
+
+func (t *TwitterNotifier) Send(ctx context.Context, text string) error {
+	_, err := sendTwitterPost(ctx, t.client, text)
+	return err
+}
+
+func sendTwitterPost(ctx context.Context, c *gotwi.Client, text string) (string, error) {
+	p := &types.CreateInput{
+		Text: gotwi.String(text),
+		//ReplySettings: gotwi.String("everyone"),
+	}
+
+	res, err := managetweet.Create(ctx, c, p)
+	if err != nil {
+		return "", fmt.Errorf("error sending tweet: %w", err)
+	}
+
+	return gotwi.StringValue(res.Data.ID), nil
+}
@@ -1,9 +1,7 @@
 package main
 
 import (
-	"context"
 	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -12,13 +10,10 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/joho/godotenv"
-	"github.com/michimani/gotwi"
-	"github.com/michimani/gotwi/tweet/managetweet"
-	"github.com/michimani/gotwi/tweet/managetweet/types"
-	"github.com/mymmrac/telego"
 )
 
 const baseAlph = 1e18
@@ -74,14 +69,16 @@ func loadEnv() {
 
 	chatIdInt, err := strconv.ParseInt(os.Getenv("CHAT_ID"), 10, 64)
 	if err != nil {
-		log.Fatalf("error getting chat id from env, err: %s\n", err)
+		log.Printf("error getting chat id from env, err: %s\n", err)
 	}
 	parameters.TelegramChatId = chatIdInt
 	parameters.TelegramTokenApi = os.Getenv("TELEGRAM_TOKEN")
+	parameters.TelegramRoutes = loadTelegramRoutes(chatIdInt)
 
 	parameters.TwitterAccessToken = os.Getenv("TWITTER_ACCESS_TOKEN")
 	parameters.TwitterAccessTokenSecret = os.Getenv("TWITTER_ACCESS_SECRET")
 
+	// comma separated lists of hosts, the healthiest fullnode is used with failover to the others
 	parameters.FullnodeApi = os.Getenv("FULLNODE_API_BASE")
 	parameters.WsFullnode = os.Getenv("FULLNODE_WS_BASE")
 
@@ -91,6 +88,81 @@ func loadEnv() {
 	parameters.PriceUrl = os.Getenv("PRICE_URL")
 	parameters.TokenListUrl = os.Getenv("TOKEN_LIST_URL")
 
+	explorerRateLimitFloat, err := strconv.ParseFloat(os.Getenv("EXPLORER_RATE_LIMIT"), 64)
+	if err != nil || explorerRateLimitFloat <= 0 {
+		// requests per second
+		explorerRateLimitFloat = 5
+	}
+	parameters.ExplorerRateLimit = explorerRateLimitFloat
+
+	explorerCacheSizeInt, err := strconv.Atoi(os.Getenv("EXPLORER_CACHE_SIZE"))
+	if err != nil {
+		explorerCacheSizeInt = 1000
+	}
+	parameters.ExplorerCacheSize = explorerCacheSizeInt
+
+	// sinks receiving the alerts, e.g. "telegram,twitter"
+	notifiersEnv := os.Getenv("NOTIFIERS")
+	if notifiersEnv == "" {
+		notifiersEnv = "telegram,twitter"
+	}
+	parameters.Notifiers = splitList(notifiersEnv)
+
+	parameters.UndeliveredFile = os.Getenv("UNDELIVERED_FILE")
+	if parameters.UndeliveredFile == "" {
+		parameters.UndeliveredFile = "./undelivered.json"
+	}
+
+	parameters.WebhookUrl = os.Getenv("WEBHOOK_URL")
+	parameters.WebhookSecret = os.Getenv("WEBHOOK_SECRET")
+	parameters.WebhookDeadLetterFile = os.Getenv("WEBHOOK_DEAD_LETTER_FILE")
+	if parameters.WebhookDeadLetterFile == "" {
+		parameters.WebhookDeadLetterFile = "./webhook-dead-letter.jsonl"
+	}
+
+	parameters.SubscriptionsFile = os.Getenv("SUBSCRIPTIONS_FILE")
+	if parameters.SubscriptionsFile == "" {
+		parameters.SubscriptionsFile = "./subscriptions.json"
+	}
+
+	parameters.CursorFile = os.Getenv("CURSOR_FILE")
+	if parameters.CursorFile == "" {
+		parameters.CursorFile = "./cursor.json"
+	}
+
+	confirmationDepthInt, err := strconv.Atoi(os.Getenv("CONFIRMATION_DEPTH"))
+	if err != nil {
+		log.Printf("error getting confirmation depth from env, err: %s\n", err)
+		confirmationDepthInt = defaultConfirmationDepth
+	}
+	parameters.ConfirmationDepth = confirmationDepthInt
+
+	// depth per alert severity, e.g. "100:0,10:3"
+	parameters.SeverityDepths = parseSeverityDepths(os.Getenv("CONFIRMATION_DEPTHS"))
+
+	parameters.ContractsFile = os.Getenv("CONTRACTS_FILE")
+	parameters.EventsCounterFile = os.Getenv("EVENTS_COUNTER_FILE")
+	if parameters.EventsCounterFile == "" {
+		parameters.EventsCounterFile = "./events.json"
+	}
+
+	eventsPollingIntervalSecInt, err := strconv.ParseInt(os.Getenv("EVENTS_POLLING_INTERVAL_SEC"), 10, 64)
+	if err != nil {
+		eventsPollingIntervalSecInt = 60
+	}
+	parameters.EventsPollingIntervalSec = eventsPollingIntervalSecInt
+
+	mempoolPollingIntervalSecInt, err := strconv.ParseInt(os.Getenv("MEMPOOL_POLLING_INTERVAL_SEC"), 10, 64)
+	if err != nil {
+		// mempool alerts are disabled by default
+		mempoolPollingIntervalSecInt = 0
+	}
+	parameters.MempoolPollingIntervalSec = mempoolPollingIntervalSecInt
+
+	// answering the bot commands needs the only getUpdates poller of the bot token, so it is opt-in
+	telegramCommands, err := strconv.ParseBool(os.Getenv("TELEGRAM_COMMANDS"))
+	parameters.TelegramCommands = err == nil && telegramCommands
+
 	debugEnv := os.Getenv("DEBUG")
 	parameters.debugMode = false
 	if debugEnv != "" {
@@ -110,6 +182,17 @@ func loadEnv() {
 
 	parameters.MinAmountTrigger = minAmountTriggerFloat
 
+	minAmountSwapTriggerFloat, err := strconv.ParseFloat(os.Getenv("MIN_AMOUNT_SWAP_TRIGGER"), 64)
+	if err != nil {
+		log.Printf("error getting min amount swap trigger from env, err: %s", err)
+		minAmountSwapTriggerFloat = parameters.MinAmountTrigger
+	}
+
+	parameters.MinAmountSwapTrigger = minAmountSwapTriggerFloat
+
+	parameters.BridgeContracts = loadBridgeContracts()
+	parameters.BridgeCoreContract = os.Getenv("BRIDGE_CORE_CONTRACT")
+
 	MinAmountCexTriggerUsdFloat, err := strconv.ParseFloat(os.Getenv("MIN_AMOUNT_TRIGGER_CEX_USD"), 64)
 	if err != nil {
 		log.Printf("error getting min amount trigger cex from env, err: %s", err)
@@ -118,6 +201,14 @@ func loadEnv() {
 
 	parameters.MinAmountCexTriggerUsd = MinAmountCexTriggerUsdFloat
 
+	minAmountBridgeTriggerUsdFloat, err := strconv.ParseFloat(os.Getenv("MIN_AMOUNT_BRIDGE_TRIGGER_USD"), 64)
+	if err != nil {
+		log.Printf("error getting min amount bridge trigger from env, err: %s", err)
+		minAmountBridgeTriggerUsdFloat = parameters.MinAmountCexTriggerUsd
+	}
+
+	parameters.MinAmountBridgeTriggerUsd = minAmountBridgeTriggerUsdFloat
+
 	pollingIntervalSecInt, err := strconv.ParseInt(os.Getenv("POLLING_INTERVAL_SEC"), 10, 64)
 	if err != nil {
 		log.Printf("error getting polling interval from env, err: %s\n", err)
@@ -127,30 +218,36 @@ func loadEnv() {
 
 }
 
-func getHttp(url string) ([]byte, int, error) {
+var httpClient = newHttpClient()
+
+func newHttpClient() *retryablehttp.Client {
 	retryClient := retryablehttp.NewClient()
 	retryClient.RetryMax = 3
 	retryClient.Logger = nil
-	resp, err := retryClient.Get(url)
+	retryClient.HTTPClient.Timeout = 30 * time.Second
 
+	return retryClient
+}
+
+// getHttp queries the url, errors are *HttpError
+func getHttp(url string) ([]byte, int, error) {
+	resp, err := httpClient.Get(url)
 	if err != nil {
-		log.Fatalf("HTTP query error: %s, url: %s\n", err, url)
-		return []byte{}, 0, fmt.Errorf("HTTP query error: %s, url: %s\n", err, url)
+		return []byte{}, 0, newHttpError(url, 0, err)
 	}
 
 	defer resp.Body.Close()
 
-	bodyBytes, _ := io.ReadAll(resp.Body)
+	bodyBytes, err := io.ReadAll(resp.Body)
 	statusCode := resp.StatusCode
+	if err != nil {
+		return []byte{}, statusCode, newHttpError(url, 0, err)
+	}
 
 	if statusCode != 200 {
-		status := resp.Status
-		return []byte{}, statusCode, fmt.Errorf("error code with url: %s, %d, %s", url, statusCode, status)
+		return []byte{}, statusCode, newHttpError(url, statusCode, fmt.Errorf("%s", resp.Status))
 	}
 
-	//bodyString := string(bodyBytes)
-	//fmt.Println("API Response as String:\n" + bodyString)
-
 	return bodyBytes, statusCode, nil
 }
 
@@ -179,11 +276,31 @@ func getRndArticles() CsvArticles {
 	return article
 }
 
+// featuredArticle is the line promoting a random article, appended to the Telegram transfer alerts
+func featuredArticle() string {
+	rndArticle := getRndArticles()
+	return fmt.Sprintf("Featured article: <a href='%s'>%s</a>", rndArticle.Url, rndArticle.Title)
+}
+
 func messageFormat(msg Message, isTelegram bool) string {
 
+	if msg.followUp {
+		return statusFormat(msg, isTelegram)
+	}
+
+	if msg.swap != nil {
+		return swapFormat(msg, isTelegram)
+	}
+
+	if msg.event != nil {
+		return eventFormat(msg, isTelegram)
+	}
+
+	if msg.bridge != nil {
+		return bridgeFormat(msg, isTelegram)
+	}
+
 	amountChain := msg.amountChain
-	namedWalletFrom := getAddressName(&msg.from)
-	namedWalletTo := getAddressName(&msg.to)
 
 	var amountFiatString string
 
@@ -201,8 +318,8 @@ func messageFormat(msg Message, isTelegram bool) string {
 
 	humanFormatAmount := Amount{Value: amountChain, Symbol: "$" + symbol}.formatHuman()
 
-	addrFrom, alertEmojiFrom := formatAddress(&namedWalletFrom, msg.from, amountChain, false)
-	addrTo, alertEmojiTo := formatAddress(&namedWalletTo, msg.to, amountChain, true)
+	addrFrom, alertEmojiFrom := formatAddresses(msg.fromAddresses, msg.from, amountChain, false)
+	addrTo, alertEmojiTo := formatAddresses(msg.toAddresses, msg.to, amountChain, true)
 
 	var alertEmoji string
 	if alertEmojiFrom != "" {
@@ -213,6 +330,8 @@ func messageFormat(msg Message, isTelegram bool) string {
 		alertEmoji = alertEmojiTo
 	}
 
+	alertEmoji = statusTag(msg.status) + alertEmoji
+
 	var text string
 	if isTelegram {
 
@@ -220,9 +339,6 @@ func messageFormat(msg Message, isTelegram bool) string {
 
 		text = fmt.Sprintf("%s %s transferred %s\n%s to %s %s\n\n<a href='%s/#/transactions/%s'>TX link</a>\n", alertEmoji, humanFormatAmount, groupsString, addrFrom, addrTo, amountFiatString, parameters.FrontendExplorerUrl, msg.txId)
 
-		rndArticle := getRndArticles()
-		text += fmt.Sprintf("Featured article: <a href='%s'>%s</a>", rndArticle.Url, rndArticle.Title)
-
 	} else {
 		text = fmt.Sprintf("%s %s transferred\n%s to %s %s\n\n%s/#/transactions/%s\n", alertEmoji, humanFormatAmount, addrFrom, addrTo, amountFiatString, parameters.FrontendExplorerUrl, msg.txId)
 
@@ -233,12 +349,84 @@ func messageFormat(msg Message, isTelegram bool) string {
 	}
 
 	fmt.Println(text)
-	if !isTelegram && len(text) > 280 {
-		return text[0:280]
+	return text
+
+}
+
+// humanAmount returns the amount with decimals applied and the symbol of the transferred asset
+func (msg Message) humanAmount() (float64, string) {
+	if msg.tokenData.Name == "" {
+		return msg.amountChain, "ALPH"
+	}
+
+	return msg.amountChain / math.Pow(10.0, float64(msg.tokenData.Decimals)), msg.tokenData.Symbol
+}
+
+// statusTag is the first line of alerts sent before their block is confirmed
+func statusTag(status AlertStatus) string {
+	switch status {
+	case statusUnconfirmed:
+		return "⏳ Unconfirmed\n"
+	case statusPending:
+		return "🕒 Pending in mempool\n"
+	}
+
+	return ""
+}
+
+// statusFormat formats the status update of an alert sent before its block was confirmed
+func statusFormat(msg Message, isTelegram bool) string {
+	amount, symbol := msg.humanAmount()
+	humanFormatAmount := Amount{Value: amount, Symbol: "$" + symbol}.formatHuman()
+
+	var status string
+	switch msg.status {
+	case statusConfirmed:
+		status = "✅ Confirmed"
+	case statusOrphaned:
+		status = "❌ Orphaned, block dropped out of the main chain"
+	case statusPending:
+		status = "🕒 Pending in mempool"
+	default:
+		status = "⏳ Unconfirmed"
+	}
+
+	var text string
+	if isTelegram {
+		text = fmt.Sprintf("%s\n%s transfer <a href='%s/#/transactions/%s'>TX link</a>", status, humanFormatAmount, parameters.FrontendExplorerUrl, msg.txId)
+	} else {
+		text = fmt.Sprintf("%s\n%s transfer %s/#/transactions/%s", status, humanFormatAmount, parameters.FrontendExplorerUrl, msg.txId)
 	}
 
 	return text
+}
 
+// max number of senders or recipients listed in an alert
+const maxListedAddresses = 3
+
+// formatAddresses formats all senders or recipients of a transfer, biggest first
+func formatAddresses(addresses []string, primary string, amount float64, to bool) (string, string) {
+	if len(addresses) == 0 {
+		addresses = []string{primary}
+	}
+
+	var formatted []string
+	var alertEmoji string
+	for i, address := range addresses {
+		if i >= maxListedAddresses {
+			formatted = append(formatted, fmt.Sprintf("+%d more", len(addresses)-maxListedAddresses))
+			break
+		}
+
+		namedWallet := getAddressName(&address)
+		addr, emoji := formatAddress(&namedWallet, address, amount, to)
+		if emoji != "" && alertEmoji == "" {
+			alertEmoji = emoji
+		}
+		formatted = append(formatted, addr)
+	}
+
+	return strings.Join(formatted, ", "), alertEmoji
 }
 
 func formatAddress(knownWallet *KnownWallet, address string, amount float64, to bool) (string, string) {
@@ -297,65 +485,56 @@ func getAddressName(address *string) KnownWallet {
 	return KnownWallet{}
 }
 
-func sendTelegramMessage(b *telego.Bot, chatId int64, message string) {
-	chatID := telego.ChatID{ID: chatId}
-	_, err := b.SendMessage(&telego.SendMessageParams{
-		ChatID:             chatID,
-		Text:               message,
-		LinkPreviewOptions: &telego.LinkPreviewOptions{IsDisabled: true},
-		ParseMode:          telego.ModeHTML,
-	})
-	if err != nil {
-		log.Printf("error telegram bot: %s", err)
-	}
-}
-
-func sendTwitterPost(c *gotwi.Client, text string) (string, error) {
-	p := &types.CreateInput{
-		Text: gotwi.String(text),
-		//ReplySettings: gotwi.String("everyone"),
-	}
-
-	res, err := managetweet.Create(context.Background(), c, p)
-	if err != nil {
-		log.Printf("Error sending tweet, err: %s", err)
-		return "", err
-	}
-
-	return gotwi.StringValue(res.Data.ID), nil
-}
+// updatePrice, updateKnownWallet and updateTokens keep the last good data when the query fails
 
 func updatePrice() {
 	dataBytes, _, err := getHttp(parameters.PriceUrl)
 	if err != nil {
-		log.Printf("Error getting price\n%s\n", err)
+		log.Printf("Error getting price, keeping %f, err: %s\n", coinGeckoPrice, err)
+		return
 	}
 
-	if len(dataBytes) > 0 {
-		var coinGeckoApi CoinGeckoPrice
-		json.Unmarshal(dataBytes, &coinGeckoApi)
-		coinGeckoPrice = coinGeckoApi.Alephium.Usd
+	var coinGeckoApi CoinGeckoPrice
+	if err := decodeJson(parameters.PriceUrl, dataBytes, &coinGeckoApi); err != nil {
+		log.Printf("Error parsing price, keeping %f, err: %s\n", coinGeckoPrice, err)
+		return
 	}
 
+	if coinGeckoApi.Alephium.Usd > 0 {
+		coinGeckoPrice = coinGeckoApi.Alephium.Usd
+	}
 }
 
 func updateKnownWallet() {
 	dataBytes, _, err := getHttp(parameters.KnownWalletUrl)
 	if err != nil {
-		log.Printf("Error getting know wallet\n%s\n", err)
+		log.Printf("Error getting known wallets, keeping %d wallets, err: %s\n", len(KnownWallets), err)
+		return
 	}
-	json.Unmarshal(dataBytes, &KnownWallets)
 
+	var knownWallets map[string]KnownWallet
+	if err := decodeJson(parameters.KnownWalletUrl, dataBytes, &knownWallets); err != nil {
+		log.Printf("Error parsing known wallets, keeping %d wallets, err: %s\n", len(KnownWallets), err)
+		return
+	}
+
+	KnownWallets = knownWallets
 }
 
 func updateTokens() {
 	dataBytes, _, err := getHttp(parameters.TokenListUrl)
 	if err != nil {
-		log.Printf("Error getting know wallet\n%s\n", err)
+		log.Printf("Error getting token list, keeping %d tokens, err: %s\n", len(Tokens.Tokens), err)
+		return
 	}
 
-	json.Unmarshal(dataBytes, &Tokens)
+	var tokens TokenList
+	if err := decodeJson(parameters.TokenListUrl, dataBytes, &tokens); err != nil {
+		log.Printf("Error parsing token list, keeping %d tokens, err: %s\n", len(Tokens.Tokens), err)
+		return
+	}
 
+	Tokens = tokens
 }
 
 func searchTokenData(contractId string) Token {
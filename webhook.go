@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+const (
+	// version of the payload, bumped on breaking changes of the schema
+	webhookSchemaVersion = 1
+	// retries after the first attempt, with exponential backoff, all attempts fit in notifierSendTimeout
+	webhookMaxRetries     = 3
+	webhookRetryWaitMin   = 1 * time.Second
+	webhookRetryWaitMax   = 8 * time.Second
+	webhookAttemptTimeout = 5 * time.Second
+
+	webhookSignatureHeader = "X-Signature-256"
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+)
+
+// WebhookPayload is the document posted for each alert, described by webhook.schema.json. Fields are only added
+// within a version, the schema is updated with them
+type WebhookPayload struct {
+	Version   int              `json:"version"`
+	Type      string           `json:"type"`
+	Status    string           `json:"status"`
+	FollowUp  bool             `json:"followUp"`
+	Timestamp string           `json:"timestamp"`
+	Transfer  *WebhookTransfer `json:"transfer,omitempty"`
+	Swap      *WebhookSwap     `json:"swap,omitempty"`
+	Event     *WebhookEvent    `json:"event,omitempty"`
+	Bridge    *WebhookBridge   `json:"bridge,omitempty"`
+	Cex       *WebhookCex      `json:"cex,omitempty"`
+	Text      string           `json:"text,omitempty"`
+}
+
+type WebhookAddress struct {
+	Address  string `json:"address"`
+	Label    string `json:"label,omitempty"`
+	Exchange string `json:"exchange,omitempty"`
+}
+
+type WebhookToken struct {
+	// empty for ALPH
+	Id       string `json:"id"`
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+}
+
+// WebhookTx locates the transaction of an on-chain alert
+type WebhookTx struct {
+	TxId      string `json:"txId"`
+	GroupFrom int    `json:"groupFrom"`
+	GroupTo   int    `json:"groupTo"`
+	Height    int    `json:"height"`
+	BlockHash string `json:"blockHash"`
+}
+
+// WebhookTransfer is sent for the transfer and swap alerts, amounts are in token units
+type WebhookTransfer struct {
+	WebhookTx
+	From          WebhookAddress   `json:"from"`
+	To            WebhookAddress   `json:"to"`
+	FromAddresses []WebhookAddress `json:"fromAddresses"`
+	ToAddresses   []WebhookAddress `json:"toAddresses"`
+	Token         WebhookToken     `json:"token"`
+	Amount        float64          `json:"amount"`
+	UsdValue      *float64         `json:"usdValue"`
+}
+
+type WebhookSwap struct {
+	Pool      WebhookAddress `json:"pool"`
+	Trader    WebhookAddress `json:"trader"`
+	TokenIn   WebhookToken   `json:"tokenIn"`
+	AmountIn  float64        `json:"amountIn"`
+	TokenOut  WebhookToken   `json:"tokenOut"`
+	AmountOut float64        `json:"amountOut"`
+}
+
+// WebhookEvent is a contract event, token and amount are only set when the rule has an amount field
+type WebhookEvent struct {
+	WebhookTx
+	Contract string        `json:"contract"`
+	Name     string        `json:"name"`
+	Rule     string        `json:"rule"`
+	Address  string        `json:"address"`
+	Token    *WebhookToken `json:"token,omitempty"`
+	Amount   *float64      `json:"amount,omitempty"`
+}
+
+type WebhookBridge struct {
+	WebhookTx
+	Contract string       `json:"contract"`
+	Chain    string       `json:"chain"`
+	Outgoing bool         `json:"outgoing"`
+	User     string       `json:"user"`
+	Token    WebhookToken `json:"token"`
+	Amount   float64      `json:"amount"`
+	UsdValue *float64     `json:"usdValue"`
+}
+
+type WebhookCex struct {
+	Exchange string  `json:"exchange"`
+	Side     string  `json:"side"`
+	Size     float64 `json:"size"`
+	Symbol   string  `json:"symbol"`
+	Total    float64 `json:"total"`
+	Price    float64 `json:"price"`
+}
+
+// WebhookDeadLetter is a line of the dead-letter file, written when an alert cannot be delivered
+type WebhookDeadLetter struct {
+	Time    string          `json:"time"`
+	Url     string          `json:"url"`
+	Error   string          `json:"error"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type WebhookNotifier struct {
+	url            string
+	secret         []byte
+	deadLetterFile string
+	client         *retryablehttp.Client
+	deadLetterMu   sync.Mutex
+}
+
+func init() {
+	registerNotifier("webhook", newWebhookNotifier)
+}
+
+func newWebhookNotifier() (Notifier, error) {
+	if parameters.WebhookUrl == "" || parameters.WebhookSecret == "" {
+		return nil, fmt.Errorf("WEBHOOK_URL and WEBHOOK_SECRET are not set")
+	}
+
+	client := newHttpClient()
+	client.RetryMax = webhookMaxRetries
+	client.RetryWaitMin = webhookRetryWaitMin
+	client.RetryWaitMax = webhookRetryWaitMax
+	client.HTTPClient.Timeout = webhookAttemptTimeout
+
+	return &WebhookNotifier{
+		url:            parameters.WebhookUrl,
+		secret:         []byte(parameters.WebhookSecret),
+		deadLetterFile: parameters.WebhookDeadLetterFile,
+		client:         client,
+	}, nil
+}
+
+func (w *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (w *WebhookNotifier) Format() Format {
+	return formatText
+}
+
+func (w *WebhookNotifier) MaxLength() int {
+	return 0
+}
+
+func (w *WebhookNotifier) RateLimit() float64 {
+	return 0
+}
+
+// Send posts an alert known by its text only, restored documents are posted as they were rendered
+func (w *WebhookNotifier) Send(ctx context.Context, text string) error {
+	if json.Valid([]byte(text)) {
+		return w.post(ctx, []byte(text))
+	}
+
+	return w.SendNotification(ctx, Notification{text: text})
+}
+
+func (w *WebhookNotifier) SendNotification(ctx context.Context, notification Notification) error {
+	if notification.msg == nil && notification.cex == nil && json.Valid([]byte(notification.text)) {
+		return w.post(ctx, []byte(notification.text))
+	}
+
+	body, err := json.Marshal(newWebhookPayload(notification))
+	if err != nil {
+		return fmt.Errorf("cannot marshal webhook payload: %w", err)
+	}
+
+	return w.post(ctx, body)
+}
+
+// Render returns the document of the alert, saved when the alert is still queued at shutdown
+func (w *WebhookNotifier) Render(notification Notification) string {
+	if notification.msg == nil && notification.cex == nil && json.Valid([]byte(notification.text)) {
+		return notification.text
+	}
+
+	body, err := json.Marshal(newWebhookPayload(notification))
+	if err != nil {
+		return notification.text
+	}
+
+	return string(body)
+}
+
+// post sends the signed document, retrying server errors with backoff. The document is written to the
+// dead-letter file once the retries are exhausted
+func (w *WebhookNotifier) post(ctx context.Context, body []byte) error {
+	err := w.postSigned(ctx, body)
+	if err != nil {
+		w.deadLetter(body, err)
+	}
+
+	return err
+}
+
+func (w *WebhookNotifier) postSigned(ctx context.Context, body []byte) error {
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create webhook request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, "sha256="+webhookSignature(w.secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return newHttpError(w.url, 0, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return newHttpError(w.url, resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, respBody))
+	}
+
+	return nil
+}
+
+// webhookSignature is the hex HMAC-SHA256 of "timestamp.body", the timestamp lets receivers reject replays
+func webhookSignature(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// deadLetter appends the document to the dead-letter file, one JSON object per line
+func (w *WebhookNotifier) deadLetter(body []byte, sendErr error) {
+	if w.deadLetterFile == "" {
+		return
+	}
+
+	line, err := json.Marshal(WebhookDeadLetter{
+		Time:    time.Now().UTC().Format(time.RFC3339),
+		Url:     w.url,
+		Error:   sendErr.Error(),
+		Payload: body,
+	})
+	if err != nil {
+		log.Printf("cannot marshal webhook dead letter, err: %s\n", err)
+		return
+	}
+
+	w.deadLetterMu.Lock()
+	defer w.deadLetterMu.Unlock()
+
+	file, err := os.OpenFile(w.deadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("cannot open webhook dead-letter file, err: %s\n", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		log.Printf("cannot write webhook dead-letter file, err: %s\n", err)
+	}
+}
+
+func newWebhookPayload(notification Notification) WebhookPayload {
+	payload := WebhookPayload{
+		Version:   webhookSchemaVersion,
+		Type:      "text",
+		Status:    "confirmed",
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	switch {
+	case notification.cex != nil:
+		msg := notification.cex
+		payload.Type = "cex"
+		payload.Cex = &WebhookCex{
+			Exchange: msg.ExchangeName,
+			Side:     msg.Side,
+			Size:     msg.AmountLeft.Value,
+			Symbol:   msg.AmountLeft.Symbol,
+			Total:    msg.AmountFiat.Value,
+			Price:    msg.Price,
+		}
+	case notification.msg != nil:
+		msg := *notification.msg
+		payload.Status = webhookStatus(msg.status)
+		payload.FollowUp = msg.followUp
+
+		switch {
+		case msg.swap != nil:
+			payload.Type = "swap"
+			payload.Transfer = newWebhookTransfer(msg)
+			payload.Swap = &WebhookSwap{
+				Pool:      newWebhookAddress(msg.swap.pool),
+				Trader:    newWebhookAddress(msg.swap.trader),
+				TokenIn:   newWebhookToken(msg.swap.tokenIn, msg.swap.symbolIn),
+				AmountIn:  msg.swap.amountIn,
+				TokenOut:  newWebhookToken(msg.swap.tokenOut, msg.swap.symbolOut),
+				AmountOut: msg.swap.amountOut,
+			}
+		case msg.event != nil:
+			payload.Type = "event"
+			payload.Event = &WebhookEvent{
+				WebhookTx: newWebhookTx(msg),
+				Contract:  msg.event.contract,
+				Name:      msg.event.name,
+				Rule:      msg.event.rule,
+				Address:   msg.event.address,
+			}
+			if msg.event.symbol != "" {
+				token := newWebhookToken(msg.event.tokenId, msg.event.symbol)
+				amount := msg.event.amount
+				payload.Event.Token = &token
+				payload.Event.Amount = &amount
+			}
+		case msg.bridge != nil:
+			payload.Type = "bridge"
+			payload.Bridge = &WebhookBridge{
+				WebhookTx: newWebhookTx(msg),
+				Contract:  msg.bridge.contract,
+				Chain:     msg.bridge.chain,
+				Outgoing:  msg.bridge.outgoing,
+				User:      msg.bridge.user,
+				Token:     newWebhookToken(msg.bridge.tokenId, msg.bridge.symbol),
+				Amount:    msg.bridge.amount,
+			}
+			if usd, ok := msg.usdValue(); ok {
+				payload.Bridge.UsdValue = &usd
+			}
+		default:
+			payload.Type = "transfer"
+			payload.Transfer = newWebhookTransfer(msg)
+		}
+	default:
+		payload.Text = notification.text
+	}
+
+	return payload
+}
+
+func newWebhookTransfer(msg Message) *WebhookTransfer {
+	tokenId, symbol, amount := msg.asset()
+
+	transfer := &WebhookTransfer{
+		WebhookTx:     newWebhookTx(msg),
+		From:          newWebhookAddress(msg.from),
+		To:            newWebhookAddress(msg.to),
+		FromAddresses: newWebhookAddresses(msg.fromAddresses),
+		ToAddresses:   newWebhookAddresses(msg.toAddresses),
+		Token:         newWebhookToken(tokenId, symbol),
+		Amount:        amount,
+	}
+	if usd, ok := msg.usdValue(); ok {
+		transfer.UsdValue = &usd
+	}
+
+	return transfer
+}
+
+func newWebhookTx(msg Message) WebhookTx {
+	return WebhookTx{TxId: msg.txId, GroupFrom: msg.groupFrom, GroupTo: msg.groupTo, Height: msg.height, BlockHash: msg.blockHash}
+}
+
+func newWebhookAddress(address string) WebhookAddress {
+	wallet := getAddressName(&address)
+	return WebhookAddress{Address: address, Label: wallet.Name, Exchange: wallet.ExchangeName}
+}
+
+func newWebhookAddresses(addresses []string) []WebhookAddress {
+	webhookAddresses := make([]WebhookAddress, 0, len(addresses))
+	for _, address := range addresses {
+		webhookAddresses = append(webhookAddresses, newWebhookAddress(address))
+	}
+
+	return webhookAddresses
+}
+
+func newWebhookToken(tokenId string, symbol string) WebhookToken {
+	if tokenId == alphFlowId {
+		return WebhookToken{Id: alphFlowId, Symbol: "ALPH", Decimals: 18}
+	}
+
+	token := WebhookToken{Id: tokenId, Symbol: symbol}
+	if tokenData := searchTokenData(tokenId); tokenData.ID != "" {
+		token.Decimals = tokenData.Decimals
+		if token.Symbol == "" {
+			token.Symbol = tokenData.Symbol
+		}
+	}
+
+	return token
+}
+
+func webhookStatus(status AlertStatus) string {
+	switch status {
+	case statusUnconfirmed:
+		return "unconfirmed"
+	case statusOrphaned:
+		return "orphaned"
+	case statusPending:
+		return "pending"
+	}
+
+	return "confirmed"
+}